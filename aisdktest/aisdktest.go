@@ -0,0 +1,72 @@
+// Package aisdktest provides helpers for testing code that produces a
+// DataStream, like provider adapters and combinators.
+package aisdktest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// CollectParts iterates the stream to the end and returns its parts. It stops
+// at the first error and returns it with the parts yielded before it.
+func CollectParts(s aisdk.DataStream) ([]aisdk.DataStreamPart, error) {
+	var parts []aisdk.DataStreamPart
+	for part, err := range s {
+		if err != nil {
+			return parts, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// AssertPartsEqual marks the test as failed if the parts differ, and returns
+// whether they are equal. Like the assert package, it doesn't stop the test.
+// Message and tool call IDs are compared by the order they first appear in,
+// not by value, so generated IDs don't break the comparison but a tool result
+// must still belong to the right call.
+func AssertPartsEqual(t testing.TB, want, got []aisdk.DataStreamPart) bool {
+	t.Helper()
+	return assert.Equal(t, normalizeIDs(want), normalizeIDs(got))
+}
+
+// normalizeIDs returns a copy of parts with every ID replaced by a
+// placeholder numbered by its first appearance.
+func normalizeIDs(parts []aisdk.DataStreamPart) []aisdk.DataStreamPart {
+	ids := make(map[string]string)
+	id := func(value string) string {
+		if value == "" {
+			return ""
+		}
+		if _, ok := ids[value]; !ok {
+			ids[value] = fmt.Sprintf("id-%d", len(ids)+1)
+		}
+		return ids[value]
+	}
+
+	normalized := make([]aisdk.DataStreamPart, len(parts))
+	for i, part := range parts {
+		switch p := part.(type) {
+		case aisdk.StartStepStreamPart:
+			p.MessageID = id(p.MessageID)
+			part = p
+		case aisdk.ToolCallStartStreamPart:
+			p.ToolCallID = id(p.ToolCallID)
+			part = p
+		case aisdk.ToolCallDeltaStreamPart:
+			p.ToolCallID = id(p.ToolCallID)
+			part = p
+		case aisdk.ToolCallStreamPart:
+			p.ToolCallID = id(p.ToolCallID)
+			part = p
+		case aisdk.ToolResultStreamPart:
+			p.ToolCallID = id(p.ToolCallID)
+			part = p
+		}
+		normalized[i] = part
+	}
+	return normalized
+}
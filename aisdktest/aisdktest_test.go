@@ -0,0 +1,63 @@
+package aisdktest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/morecommits/aisdk-go/aisdktest"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT records failures instead of failing the test.
+type recordingT struct {
+	testing.TB
+	failed  bool
+	stopped bool
+}
+
+func (r *recordingT) Helper()               {}
+func (r *recordingT) Name() string          { return "recording" }
+func (r *recordingT) Errorf(string, ...any) { r.failed = true }
+func (r *recordingT) FailNow()              { r.failed, r.stopped = true, true }
+
+func TestCollectParts(t *testing.T) {
+	t.Parallel()
+
+	stream := aisdk.DataStream(func(yield func(aisdk.DataStreamPart, error) bool) {
+		if !yield(aisdk.TextStreamPart{Content: "Hello"}, nil) {
+			return
+		}
+		yield(nil, errors.New("connection reset"))
+	})
+	parts, err := aisdktest.CollectParts(stream)
+	require.EqualError(t, err, "connection reset")
+	require.Equal(t, []aisdk.DataStreamPart{aisdk.TextStreamPart{Content: "Hello"}}, parts)
+}
+
+func TestAssertPartsEqual(t *testing.T) {
+	t.Parallel()
+
+	want := []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStreamPart{ToolCallID: "call_1", ToolName: "now", Args: map[string]any{}},
+		aisdk.ToolResultStreamPart{ToolCallID: "call_1", Result: "noon"},
+	}
+	aisdktest.AssertPartsEqual(t, want, []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_generated"},
+		aisdk.ToolCallStreamPart{ToolCallID: "toolu_generated", ToolName: "now", Args: map[string]any{}},
+		aisdk.ToolResultStreamPart{ToolCallID: "toolu_generated", Result: "noon"},
+	})
+
+	// A result for another call still differs.
+	var rt recordingT
+	equal := aisdktest.AssertPartsEqual(&rt, want, []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_generated"},
+		aisdk.ToolCallStreamPart{ToolCallID: "toolu_generated", ToolName: "now", Args: map[string]any{}},
+		aisdk.ToolResultStreamPart{ToolCallID: "toolu_other", Result: "noon"},
+	})
+	require.False(t, equal)
+	require.True(t, rt.failed)
+	// The test goes on, like with the assert package.
+	require.False(t, rt.stopped)
+}
@@ -3,15 +3,39 @@ package aisdk
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"unicode"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/packages/ssestream"
 )
 
+// AnthropicToolsOption configures ToolsToAnthropic.
+type AnthropicToolsOption func(*anthropicToolsConfig)
+
+type anthropicToolsConfig struct {
+	cache bool
+}
+
+// WithToolsCache marks the last tool definition with an ephemeral
+// cache_control breakpoint. Anthropic caches the whole prompt prefix up to a
+// breakpoint, so this caches every tool definition and they aren't billed as
+// full input tokens on every turn.
+func WithToolsCache() AnthropicToolsOption {
+	return func(c *anthropicToolsConfig) {
+		c.cache = true
+	}
+}
+
 // ToolsToAnthropic converts the tool format to Anthropic's API format.
-func ToolsToAnthropic(tools []Tool) []anthropic.ToolUnionParam {
+func ToolsToAnthropic(tools []Tool, opts ...AnthropicToolsOption) []anthropic.ToolUnionParam {
+	var config anthropicToolsConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
 	anthropicTools := []anthropic.ToolUnionParam{}
 	for _, tool := range tools {
 		// Construct the ToolInputSchemaParam struct directly
@@ -39,15 +63,53 @@ func ToolsToAnthropic(tools []Tool) []anthropic.ToolUnionParam {
 			},
 		})
 	}
+	if config.cache && len(anthropicTools) > 0 {
+		anthropicTools[len(anthropicTools)-1].OfTool.CacheControl = anthropic.NewCacheControlEphemeralParam()
+	}
 	return anthropicTools
 }
 
+// anthropicMinThinkingBudget is the smallest thinking budget Anthropic accepts.
+const anthropicMinThinkingBudget = 1024
+
+// ThinkingBudgetToAnthropic converts the thinking budget to Anthropic's thinking
+// config. Anthropic requires the budget to be at least 1024 tokens and less than
+// the max tokens of the request.
+func ThinkingBudgetToAnthropic(budget ThinkingBudget, maxTokens int64) (anthropic.ThinkingConfigParamUnion, error) {
+	if budget == 0 {
+		return anthropic.ThinkingConfigParamUnion{
+			OfDisabled: &anthropic.ThinkingConfigDisabledParam{},
+		}, nil
+	}
+	if budget < anthropicMinThinkingBudget {
+		return anthropic.ThinkingConfigParamUnion{}, fmt.Errorf("thinking budget of %d tokens is below Anthropic's minimum of %d", budget, anthropicMinThinkingBudget)
+	}
+	if int64(budget) >= maxTokens {
+		return anthropic.ThinkingConfigParamUnion{}, fmt.Errorf("thinking budget of %d tokens must be less than max tokens of %d", budget, maxTokens)
+	}
+	return anthropic.ThinkingConfigParamUnion{
+		OfEnabled: &anthropic.ThinkingConfigEnabledParam{
+			BudgetTokens: int64(budget),
+		},
+	}, nil
+}
+
+// anthropicImageTypes are the image MIME types supported by Anthropic.
+var anthropicImageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// anthropicPDFType is the MIME type of files sent to Anthropic as documents
+// instead of images.
+const anthropicPDFType = "application/pdf"
+
 // MessagesToAnthropic converts internal message format to Anthropic's API format.
 // It extracts system messages into a separate slice of TextBlockParams and groups
 // consecutive user/tool and assistant messages according to Anthropic's rules.
 // It handles the case where a single assistant message part contains both the
 // tool call and its result, splitting them into the required assistant tool_use
-// and user tool_result blocks.
+// and user tool_result blocks. A trailing assistant message is sent as a
+// prefill, which the response continues; use WithPrefill to include it in the
+// streamed message. It returns an error if there are no messages
+// besides system messages, since Anthropic requires at least one.
 func MessagesToAnthropic(messages []Message) ([]anthropic.MessageParam, []anthropic.TextBlockParam, error) {
 	anthropicMessages := []anthropic.MessageParam{}
 
@@ -58,7 +120,9 @@ func MessagesToAnthropic(messages []Message) ([]anthropic.MessageParam, []anthro
 		content := []anthropic.ContentBlockParamUnion{}
 
 		switch message.Role {
-		case "system":
+		case "system", "developer":
+			// Anthropic has no developer role, so developer messages are
+			// sent as the system prompt.
 			if len(systemPrompt) > 0 {
 				return nil, nil, fmt.Errorf("multiple system messages found")
 			}
@@ -74,28 +138,59 @@ func MessagesToAnthropic(messages []Message) ([]anthropic.MessageParam, []anthro
 			for _, part := range message.Parts {
 				switch part.Type {
 				case PartTypeText:
+					// Anthropic rejects empty and whitespace-only text blocks,
+					// which an empty completion can leave behind.
+					if strings.TrimSpace(part.Text) == "" {
+						continue
+					}
 					content = append(content, anthropic.ContentBlockParamUnion{
 						OfText: &anthropic.TextBlockParam{
 							Text: part.Text,
 						},
 					})
+				case PartTypeReasoning:
+					// With extended thinking, Anthropic requires the thinking
+					// blocks of previous turns to be sent back unmodified.
+					// Reasoning without a signature can't be verified, so it
+					// is omitted.
+					for _, detail := range part.Details {
+						switch detail.Type {
+						case "text":
+							if detail.Signature == "" {
+								continue
+							}
+							content = append(content, anthropic.ContentBlockParamUnion{
+								OfThinking: &anthropic.ThinkingBlockParam{
+									Thinking:  detail.Text,
+									Signature: detail.Signature,
+								},
+							})
+						case "redacted":
+							content = append(content, anthropic.ContentBlockParamUnion{
+								OfRedactedThinking: &anthropic.RedactedThinkingBlockParam{
+									Data: detail.Data,
+								},
+							})
+						}
+					}
 				case PartTypeToolInvocation:
 					if part.ToolInvocation == nil {
 						return nil, nil, fmt.Errorf("assistant message part has type tool-invocation but nil ToolInvocation field (ID: %s)", message.ID)
 					}
-					argsJSON, err := json.Marshal(part.ToolInvocation.Args)
+					call, result := SplitToolInvocation(part)
+					argsJSON, err := json.Marshal(call.ToolInvocation.Args)
 					if err != nil {
-						return nil, nil, fmt.Errorf("marshalling tool input for call %s: %w", part.ToolInvocation.ToolCallID, err)
+						return nil, nil, fmt.Errorf("marshalling tool input for call %s: %w", call.ToolInvocation.ToolCallID, err)
 					}
 					content = append(content, anthropic.ContentBlockParamUnion{
 						OfToolUse: &anthropic.ToolUseBlockParam{
-							ID:    part.ToolInvocation.ToolCallID,
+							ID:    call.ToolInvocation.ToolCallID,
 							Input: json.RawMessage(argsJSON),
-							Name:  part.ToolInvocation.ToolName,
+							Name:  call.ToolInvocation.ToolName,
 						},
 					})
 
-					if part.ToolInvocation.State != ToolInvocationStateResult {
+					if result.ToolInvocation == nil {
 						continue
 					}
 
@@ -107,7 +202,10 @@ func MessagesToAnthropic(messages []Message) ([]anthropic.MessageParam, []anthro
 					content = nil
 
 					resultContent := []anthropic.ToolResultBlockParamContentUnion{}
-					resultParts, err := toolResultToParts(part.ToolInvocation.Result)
+					// Tool results can't hold documents, so PDFs follow the
+					// tool result as document blocks of the same message.
+					var documents []anthropic.ContentBlockParamUnion
+					resultParts, err := toolResultToParts(result.ToolInvocation.Result)
 					if err != nil {
 						return nil, nil, fmt.Errorf("failed to convert tool call result to parts: %w", err)
 					}
@@ -118,30 +216,39 @@ func MessagesToAnthropic(messages []Message) ([]anthropic.MessageParam, []anthro
 								OfText: &anthropic.TextBlockParam{Text: resultPart.Text},
 							})
 						case PartTypeFile:
+							if resultPart.MimeType == anthropicPDFType {
+								documents = append(documents, anthropic.ContentBlockParamUnion{
+									OfDocument: &anthropic.DocumentBlockParam{
+										Source: anthropicDocumentSource(resultPart),
+									},
+								})
+								continue
+							}
+							if err := checkImageType("Anthropic", resultPart.MimeType, anthropicImageTypes); err != nil {
+								return nil, nil, err
+							}
 							resultContent = append(resultContent, anthropic.ToolResultBlockParamContentUnion{
 								OfImage: &anthropic.ImageBlockParam{
-									Source: anthropic.ImageBlockParamSourceUnion{
-										OfBase64: &anthropic.Base64ImageSourceParam{
-											Data:      base64.StdEncoding.EncodeToString(resultPart.Data),
-											MediaType: anthropic.Base64ImageSourceMediaType(resultPart.MimeType),
-										},
-									},
+									Source: anthropicImageSource(resultPart),
 								},
 							})
 						}
 					}
 
+					toolResult := &anthropic.ToolResultBlockParam{
+						ToolUseID: result.ToolInvocation.ToolCallID,
+						Content:   resultContent,
+					}
+					if result.ToolInvocation.IsError {
+						toolResult.IsError = anthropic.Bool(true)
+					}
+
 					// Send the tool result as a separate message with the role as user.
 					anthropicMessages = append(anthropicMessages, anthropic.MessageParam{
 						Role: anthropic.MessageParamRoleUser,
-						Content: []anthropic.ContentBlockParamUnion{
-							{
-								OfToolResult: &anthropic.ToolResultBlockParam{
-									ToolUseID: part.ToolInvocation.ToolCallID,
-									Content:   resultContent,
-								},
-							},
-						},
+						Content: append([]anthropic.ContentBlockParamUnion{
+							{OfToolResult: toolResult},
+						}, documents...),
 					})
 					content = nil
 				}
@@ -155,14 +262,20 @@ func MessagesToAnthropic(messages []Message) ([]anthropic.MessageParam, []anthro
 						OfText: &anthropic.TextBlockParam{Text: part.Text},
 					})
 				case PartTypeFile:
+					if part.MimeType == anthropicPDFType {
+						content = append(content, anthropic.ContentBlockParamUnion{
+							OfDocument: &anthropic.DocumentBlockParam{
+								Source: anthropicDocumentSource(part),
+							},
+						})
+						continue
+					}
+					if err := checkImageType("Anthropic", part.MimeType, anthropicImageTypes); err != nil {
+						return nil, nil, err
+					}
 					content = append(content, anthropic.ContentBlockParamUnion{
 						OfImage: &anthropic.ImageBlockParam{
-							Source: anthropic.ImageBlockParamSourceUnion{
-								OfBase64: &anthropic.Base64ImageSourceParam{
-									Data:      base64.StdEncoding.EncodeToString(part.Data),
-									MediaType: anthropic.Base64ImageSourceMediaType(part.MimeType),
-								},
-							},
+							Source: anthropicImageSource(part),
 						},
 					})
 				case PartTypeToolInvocation:
@@ -175,20 +288,45 @@ func MessagesToAnthropic(messages []Message) ([]anthropic.MessageParam, []anthro
 
 		if len(message.Attachments) > 0 {
 			for _, attachment := range message.Attachments {
-				// URLs typically have the mime prefixing as a URL.
-				parts := strings.SplitN(attachment.URL, ",", 2)
-				if len(parts) != 2 {
-					return nil, nil, fmt.Errorf("invalid attachment URL: %s", attachment.URL)
+				// Base64 data URLs are inlined, and other URLs are referenced.
+				var data string
+				if rest, ok := strings.CutPrefix(attachment.URL, "data:"); ok {
+					header, encoded, ok := strings.Cut(rest, ",")
+					if !ok || !strings.HasSuffix(header, ";base64") {
+						return nil, nil, fmt.Errorf("invalid attachment URL: %s", attachment.URL)
+					}
+					data = encoded
 				}
-				content = append(content, anthropic.ContentBlockParamUnion{
-					OfImage: &anthropic.ImageBlockParam{
-						Source: anthropic.ImageBlockParamSourceUnion{
-							OfBase64: &anthropic.Base64ImageSourceParam{
-								Data:      parts[1],
-								MediaType: anthropic.Base64ImageSourceMediaType(attachment.ContentType),
-							},
+				if attachment.ContentType == anthropicPDFType {
+					source := anthropic.DocumentBlockParamSourceUnion{
+						OfURL: &anthropic.URLPDFSourceParam{URL: attachment.URL},
+					}
+					if data != "" {
+						source = anthropic.DocumentBlockParamSourceUnion{
+							OfBase64: &anthropic.Base64PDFSourceParam{Data: data},
+						}
+					}
+					content = append(content, anthropic.ContentBlockParamUnion{
+						OfDocument: &anthropic.DocumentBlockParam{Source: source},
+					})
+					continue
+				}
+				if err := checkImageType("Anthropic", attachment.ContentType, anthropicImageTypes); err != nil {
+					return nil, nil, err
+				}
+				source := anthropic.ImageBlockParamSourceUnion{
+					OfURL: &anthropic.URLImageSourceParam{URL: attachment.URL},
+				}
+				if data != "" {
+					source = anthropic.ImageBlockParamSourceUnion{
+						OfBase64: &anthropic.Base64ImageSourceParam{
+							Data:      data,
+							MediaType: anthropic.Base64ImageSourceMediaType(attachment.ContentType),
 						},
-					},
+					}
+				}
+				content = append(content, anthropic.ContentBlockParamUnion{
+					OfImage: &anthropic.ImageBlockParam{Source: source},
 				})
 			}
 		}
@@ -201,18 +339,110 @@ func MessagesToAnthropic(messages []Message) ([]anthropic.MessageParam, []anthro
 		}
 	}
 
+	// Anthropic rejects requests without messages with a less helpful error.
+	if len(anthropicMessages) == 0 {
+		return nil, nil, errors.New("at least one non-system message required")
+	}
+
+	// A trailing assistant message prefills the response, which Anthropic
+	// rejects if it ends with whitespace.
+	last := anthropicMessages[len(anthropicMessages)-1]
+	if last.Role == anthropic.MessageParamRoleAssistant {
+		if block := last.Content[len(last.Content)-1].OfText; block != nil {
+			block.Text = strings.TrimRightFunc(block.Text, unicode.IsSpace)
+		}
+	}
+
 	return anthropicMessages, systemPrompt, nil
 }
 
+// anthropicRefusal is the content of the RefusalStreamPart for the refusal stop
+// reason, which has no content of its own.
+const anthropicRefusal = "The model declined to respond."
+
+// anthropicImageSource returns the image source for a file part, referencing
+// the URL if the part has one instead of inline data.
+func anthropicImageSource(part Part) anthropic.ImageBlockParamSourceUnion {
+	if part.URL != "" {
+		return anthropic.ImageBlockParamSourceUnion{
+			OfURL: &anthropic.URLImageSourceParam{URL: part.URL},
+		}
+	}
+	return anthropic.ImageBlockParamSourceUnion{
+		OfBase64: &anthropic.Base64ImageSourceParam{
+			Data:      base64.StdEncoding.EncodeToString(part.Data),
+			MediaType: anthropic.Base64ImageSourceMediaType(part.MimeType),
+		},
+	}
+}
+
+// anthropicDocumentSource returns the PDF source for a file part, referencing
+// the URL if the part has one and inlining the data otherwise.
+func anthropicDocumentSource(part Part) anthropic.DocumentBlockParamSourceUnion {
+	if part.URL != "" {
+		return anthropic.DocumentBlockParamSourceUnion{
+			OfURL: &anthropic.URLPDFSourceParam{URL: part.URL},
+		}
+	}
+	return anthropic.DocumentBlockParamSourceUnion{
+		OfBase64: &anthropic.Base64PDFSourceParam{
+			Data: base64.StdEncoding.EncodeToString(part.Data),
+		},
+	}
+}
+
+// AnthropicStreamOption configures AnthropicToDataStream.
+type AnthropicStreamOption func(*anthropicStreamConfig)
+
+type anthropicStreamConfig struct {
+	usageUpdates bool
+}
+
+// WithUsageUpdates yields a MessageAnnotationStreamPart with the usage so far
+// whenever Anthropic reports it, at the start of the message and with each
+// message delta, e.g. for a live token counter. The annotations are objects
+// like {"type":"usage","promptTokens":10,"completionTokens":5}, and are
+// accumulated on the message like any other annotation.
+func WithUsageUpdates() AnthropicStreamOption {
+	return func(c *anthropicStreamConfig) {
+		c.usageUpdates = true
+	}
+}
+
 // AnthropicToDataStream pipes an Anthropic stream to a DataStream.
-func AnthropicToDataStream(stream *ssestream.Stream[anthropic.MessageStreamEventUnion]) DataStream {
+// Errors of the stream are yielded as *ProviderError, wrapping a
+// *RateLimitError if the request was rate limited, or an
+// *AnthropicStreamError for an error event in the middle of the stream.
+//
+// Tool call arguments are streamed as deltas, and the complete call is yielded
+// as a ToolCallStreamPart when its content block stops.
+//
+// When the response stops at max_tokens, the step is finished with IsContinued,
+// so that the accumulator keeps the message open for a continuation request.
+// To continue, drop the FinishMessageStreamPart and append the stream of the
+// continuation request.
+//
+// Usage is reported on every FinishStepStreamPart, and the usage of the
+// FinishMessageStreamPart is the final one. See WithUsageUpdates for the usage
+// while the response streams.
+func AnthropicToDataStream(stream *ssestream.Stream[anthropic.MessageStreamEventUnion], opts ...AnthropicStreamOption) DataStream {
+	var config anthropicStreamConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
 	return func(yield func(DataStreamPart, error) bool) {
 		var lastChunk *anthropic.MessageStreamEventUnion
 		var finalReason FinishReason = FinishReasonUnknown
+		var stopSequence string
+		var usage Usage
 		var currentToolCall struct {
-			ID   string
-			Args string
+			ID    string
+			Name  string
+			Args  string
+			Index int
 		}
+		// toolCalls counts the tool calls of the message, to index them.
+		var toolCalls int
 
 		for stream.Next() {
 			chunk := stream.Current()
@@ -221,11 +451,19 @@ func AnthropicToDataStream(stream *ssestream.Stream[anthropic.MessageStreamEvent
 			event := chunk.AsAny()
 			switch event := event.(type) {
 			case anthropic.MessageStartEvent:
+				usage.PromptTokens = &event.Message.Usage.InputTokens
+				toolCalls = 0
 				if !yield(StartStepStreamPart{
 					MessageID: event.Message.ID,
 				}, nil) {
 					return
 				}
+				if config.usageUpdates {
+					outputTokens := event.Message.Usage.OutputTokens
+					if !yield(usageAnnotation(Usage{PromptTokens: usage.PromptTokens, CompletionTokens: &outputTokens}), nil) {
+						return
+					}
+				}
 
 			case anthropic.ContentBlockDeltaEvent:
 				switch delta := event.Delta.AsAny().(type) {
@@ -239,6 +477,7 @@ func AnthropicToDataStream(stream *ssestream.Stream[anthropic.MessageStreamEvent
 					if !yield(ToolCallDeltaStreamPart{
 						ToolCallID:    currentToolCall.ID,
 						ArgsTextDelta: delta.PartialJSON,
+						Index:         currentToolCall.Index,
 					}, nil) {
 						return
 					}
@@ -246,30 +485,78 @@ func AnthropicToDataStream(stream *ssestream.Stream[anthropic.MessageStreamEvent
 					if !yield(ReasoningStreamPart{Content: delta.Thinking}, nil) {
 						return
 					}
+				case anthropic.SignatureDelta:
+					if !yield(ReasoningSignatureStreamPart{Signature: delta.Signature}, nil) {
+						return
+					}
 				}
 
 			case anthropic.ContentBlockStartEvent:
-				if block, ok := event.ContentBlock.AsAny().(anthropic.ToolUseBlock); ok {
+				switch block := event.ContentBlock.AsAny().(type) {
+				case anthropic.ToolUseBlock:
 					currentToolCall.ID = block.ID
+					currentToolCall.Name = block.Name
 					currentToolCall.Args = ""
+					currentToolCall.Index = toolCalls
+					toolCalls++
 
 					if !yield(ToolCallStartStreamPart{
 						ToolCallID: block.ID,
 						ToolName:   block.Name,
+						Index:      currentToolCall.Index,
 					}, nil) {
 						return
 					}
+				case anthropic.RedactedThinkingBlock:
+					if !yield(RedactedReasoningStreamPart{Data: block.Data}, nil) {
+						return
+					}
 				}
 
+			case anthropic.ContentBlockStopEvent:
+				// Only tool_use blocks need finalizing. Their arguments are
+				// complete at the end of the block, so the call is emitted as a
+				// whole and consumers don't need to assemble it from deltas.
+				if currentToolCall.ID == "" {
+					break
+				}
+				args := map[string]any{}
+				if strings.TrimSpace(currentToolCall.Args) != "" {
+					if err := json.Unmarshal([]byte(currentToolCall.Args), &args); err != nil {
+						yield(nil, &ProviderError{Provider: "anthropic", Err: fmt.Errorf("failed to parse arguments for tool call %s: %w", currentToolCall.ID, err)})
+						return
+					}
+				}
+				if !yield(ToolCallStreamPart{
+					ToolCallID: currentToolCall.ID,
+					ToolName:   currentToolCall.Name,
+					Args:       args,
+				}, nil) {
+					return
+				}
+				currentToolCall.ID, currentToolCall.Name, currentToolCall.Args = "", "", ""
+
 			case anthropic.MessageDeltaEvent:
+				// Output tokens in the delta are cumulative.
+				usage.CompletionTokens = &event.Usage.OutputTokens
+				if config.usageUpdates && !yield(usageAnnotation(usage), nil) {
+					return
+				}
+				if event.Delta.StopReason == "refusal" {
+					finalReason = FinishReasonContentFilter
+					if !yield(RefusalStreamPart{Content: anthropicRefusal}, nil) {
+						return
+					}
+				}
+				if event.Delta.StopReason == "max_tokens" {
+					finalReason = FinishReasonLength
+				}
 				if event.Delta.StopReason == "tool_use" {
 					finalReason = FinishReasonToolCalls
-
-					// Reset current tool call after emitting the final delta
-					currentToolCall = struct {
-						ID   string
-						Args string
-					}{}
+				}
+				if event.Delta.StopReason == "stop_sequence" {
+					finalReason = FinishReasonStop
+					stopSequence = event.Delta.StopSequence
 				}
 
 			case anthropic.MessageStopEvent:
@@ -281,7 +568,11 @@ func AnthropicToDataStream(stream *ssestream.Stream[anthropic.MessageStreamEvent
 				// Send final finish step
 				if !yield(FinishStepStreamPart{
 					FinishReason: finalReason,
-					IsContinued:  false,
+					Usage:        usage,
+					// A response cut off at max_tokens can be continued by
+					// another request, whose text continues this message.
+					IsContinued:  finalReason == FinishReasonLength,
+					StopSequence: stopSequence,
 				}, nil) {
 					return
 				}
@@ -289,6 +580,7 @@ func AnthropicToDataStream(stream *ssestream.Stream[anthropic.MessageStreamEvent
 				// Send final finish message
 				if !yield(FinishMessageStreamPart{
 					FinishReason: finalReason,
+					Usage:        usage,
 				}, nil) {
 					return
 				}
@@ -297,7 +589,16 @@ func AnthropicToDataStream(stream *ssestream.Stream[anthropic.MessageStreamEvent
 
 		// Handle any errors from the stream
 		if err := stream.Err(); err != nil {
-			yield(nil, fmt.Errorf("anthropic stream error: %w", err))
+			var apiErr *anthropic.Error
+			if errors.As(err, &apiErr) && apiErr.Response != nil {
+				err = rateLimitError(apiErr.StatusCode, apiErr.Response.Header, err)
+			} else if streamErr := parseAnthropicStreamError(err); streamErr != nil {
+				err = streamErr
+				if streamErr.Type == "rate_limit_error" {
+					err = &RateLimitError{Err: streamErr}
+				}
+			}
+			yield(nil, &ProviderError{Provider: "anthropic", Err: err})
 			return
 		}
 
@@ -310,7 +611,164 @@ func AnthropicToDataStream(stream *ssestream.Stream[anthropic.MessageStreamEvent
 
 			yield(FinishMessageStreamPart{
 				FinishReason: finalReason,
+				Usage:        usage,
 			}, nil)
 		}
 	}
 }
+
+// AnthropicStreamError is an error event that Anthropic sent in the middle
+// of a stream, e.g. when the API is overloaded. It is wrapped in a
+// *ProviderError, so detect it with errors.As. A rate_limit_error is also
+// wrapped in a *RateLimitError.
+type AnthropicStreamError struct {
+	// Type is Anthropic's error type, e.g. "overloaded_error" or "api_error".
+	Type    string
+	Message string
+}
+
+func (e *AnthropicStreamError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
+
+// Overloaded returns whether Anthropic was overloaded, in which case the
+// request can be retried after a while.
+func (e *AnthropicStreamError) Overloaded() bool {
+	return e.Type == "overloaded_error"
+}
+
+// anthropicStreamErrorPrefix prefixes the data of an error event in the
+// errors of the SDK's stream.
+const anthropicStreamErrorPrefix = "received error while streaming: "
+
+// parseAnthropicStreamError returns the error event of a stream error, or nil
+// if the error isn't an error event.
+func parseAnthropicStreamError(err error) *AnthropicStreamError {
+	data, ok := strings.CutPrefix(err.Error(), anthropicStreamErrorPrefix)
+	if !ok {
+		return nil
+	}
+	var event struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(data), &event) != nil || event.Error.Type == "" {
+		return nil
+	}
+	return &AnthropicStreamError{Type: event.Error.Type, Message: event.Error.Message}
+}
+
+// AnthropicResponseToMessage converts a non-streaming message to a Message, with
+// the same representation as an accumulated AnthropicToDataStream. Tool calls
+// are complete, like those passed through WithToolCalling.
+func AnthropicResponseToMessage(resp anthropic.Message) (Message, error) {
+	parts := []DataStreamPart{StartStepStreamPart{MessageID: resp.ID}}
+
+	var toolCalls int
+	for _, block := range resp.Content {
+		switch block := block.AsAny().(type) {
+		case anthropic.TextBlock:
+			parts = append(parts, TextStreamPart{Content: block.Text})
+		case anthropic.ThinkingBlock:
+			parts = append(parts, ReasoningStreamPart{Content: block.Thinking})
+			if block.Signature != "" {
+				parts = append(parts, ReasoningSignatureStreamPart{Signature: block.Signature})
+			}
+		case anthropic.RedactedThinkingBlock:
+			parts = append(parts, RedactedReasoningStreamPart{Data: block.Data})
+		case anthropic.ToolUseBlock:
+			args := map[string]any{}
+			if len(block.Input) > 0 {
+				if err := json.Unmarshal(block.Input, &args); err != nil {
+					return Message{}, fmt.Errorf("failed to parse input for tool call %s: %w", block.ID, err)
+				}
+			}
+			parts = append(parts, ToolCallStartStreamPart{
+				ToolCallID: block.ID,
+				ToolName:   block.Name,
+				Index:      toolCalls,
+			}, ToolCallStreamPart{
+				ToolCallID: block.ID,
+				ToolName:   block.Name,
+				Args:       args,
+			})
+			toolCalls++
+		}
+	}
+
+	var finishReason FinishReason
+	switch resp.StopReason {
+	case "tool_use":
+		finishReason = FinishReasonToolCalls
+	case "max_tokens":
+		finishReason = FinishReasonLength
+	case "refusal":
+		finishReason = FinishReasonContentFilter
+		parts = append(parts, RefusalStreamPart{Content: anthropicRefusal})
+	default:
+		finishReason = FinishReasonStop
+	}
+
+	usage := Usage{
+		PromptTokens:     &resp.Usage.InputTokens,
+		CompletionTokens: &resp.Usage.OutputTokens,
+	}
+	parts = append(parts, FinishStepStreamPart{
+		FinishReason: finishReason,
+		Usage:        usage,
+		StopSequence: resp.StopSequence,
+	}, FinishMessageStreamPart{
+		FinishReason: finishReason,
+		Usage:        usage,
+	})
+
+	return partsToMessage(parts)
+}
+
+// AnthropicBatchRequests builds a Message Batches request for every chat, for
+// bulk processing that doesn't need streaming. The chat ID is used as the
+// custom ID, so it must be set and unique. params holds the settings shared by
+// all requests, like the model, max tokens and tools; its messages and system
+// prompt are replaced by each chat's.
+func AnthropicBatchRequests(chats []Chat, params anthropic.MessageBatchNewParamsRequestParams) ([]anthropic.MessageBatchNewParamsRequest, error) {
+	requests := make([]anthropic.MessageBatchNewParamsRequest, 0, len(chats))
+	seen := make(map[string]bool, len(chats))
+	for _, chat := range chats {
+		if chat.ID == "" {
+			return nil, errors.New("chat ID is required as the batch custom ID")
+		}
+		if seen[chat.ID] {
+			return nil, fmt.Errorf("duplicate chat ID %q in batch", chat.ID)
+		}
+		seen[chat.ID] = true
+
+		messages, system, err := MessagesToAnthropic(chat.Messages)
+		if err != nil {
+			return nil, fmt.Errorf("converting chat %q: %w", chat.ID, err)
+		}
+		chatParams := params
+		chatParams.Messages = messages
+		chatParams.System = system
+		requests = append(requests, anthropic.MessageBatchNewParamsRequest{
+			CustomID: chat.ID,
+			Params:   chatParams,
+		})
+	}
+	return requests, nil
+}
+
+// AnthropicBatchResultToMessage converts the result of a batch request to a
+// Message, like AnthropicResponseToMessage. Results that didn't succeed are
+// returned as an error.
+func AnthropicBatchResultToMessage(resp anthropic.MessageBatchIndividualResponse) (Message, error) {
+	switch resp.Result.Type {
+	case "succeeded":
+		return AnthropicResponseToMessage(resp.Result.Message)
+	case "errored":
+		return Message{}, fmt.Errorf("batch request %q errored: %s: %s", resp.CustomID, resp.Result.Error.Error.Type, resp.Result.Error.Error.Message)
+	default:
+		return Message{}, fmt.Errorf("batch request %q was not processed: %s", resp.CustomID, resp.Result.Type)
+	}
+}
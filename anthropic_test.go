@@ -3,11 +3,13 @@ package aisdk_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -97,6 +99,7 @@ data: {"type":"message_stop" }`
 				Type: aisdk.PartTypeToolInvocation,
 				ToolInvocation: &aisdk.ToolInvocation{
 					State:      aisdk.ToolInvocationStateResult,
+					Step:       intPtr(0),
 					ToolCallID: "toolu_01RA76iwg1LbKuDjJnc6ym45",
 					ToolName:   "print",
 					Args:       map[string]any{"message": "hello world"},
@@ -107,6 +110,8 @@ data: {"type":"message_stop" }`
 	}
 
 	require.EqualExportedValues(t, expectedMessages, acc.Messages())
+	require.Equal(t, aisdk.Usage{PromptTokens: int64Ptr(408), CompletionTokens: int64Ptr(71)}, acc.Usage())
+	require.Equal(t, acc.Usage(), acc.TotalUsage())
 
 	// --- Add conversion back check ---
 	anthropicMsgs, systemPrompts, err := aisdk.MessagesToAnthropic(acc.Messages())
@@ -221,3 +226,944 @@ func TestMessagesToAnthropic_Live(t *testing.T) {
 	})
 	require.NoError(t, streamErr)
 }
+
+func TestAnthropicToDataStream_EmptyCompletion(t *testing.T) {
+	t.Parallel()
+
+	anthropicResponses := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_empty","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"\n\n"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":1}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+	})
+	typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range aisdk.AnthropicToDataStream(typedStream).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.Len(t, acc.Messages(), 1)
+
+	messages := []aisdk.Message{
+		{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Hello"}}},
+		acc.Messages()[0],
+		{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Are you there?"}}},
+	}
+	anthropicMsgs, _, err := aisdk.MessagesToAnthropic(messages)
+	require.NoError(t, err)
+
+	// The empty assistant message must not be sent.
+	require.Len(t, anthropicMsgs, 2)
+	for _, msg := range anthropicMsgs {
+		require.Equal(t, anthropic.MessageParamRoleUser, msg.Role)
+	}
+}
+
+func TestAnthropicToDataStream_Refusal(t *testing.T) {
+	t.Parallel()
+
+	anthropicResponses := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_refusal","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"refusal","stop_sequence":null},"usage":{"output_tokens":1}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+	})
+	typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range aisdk.AnthropicToDataStream(typedStream).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.NotEmpty(t, acc.Refusal())
+	require.Equal(t, aisdk.FinishReasonContentFilter, acc.FinishReason())
+}
+
+func TestAnthropicToDataStream_UsageUpdates(t *testing.T) {
+	t.Parallel()
+
+	anthropicResponses := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_usage","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello!"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":6}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+	})
+	typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+
+	var annotations []any
+	var acc aisdk.DataStreamAccumulator
+	for part, err := range aisdk.AnthropicToDataStream(typedStream, aisdk.WithUsageUpdates()).WithAccumulator(&acc) {
+		require.NoError(t, err)
+		if p, ok := part.(aisdk.MessageAnnotationStreamPart); ok {
+			annotations = append(annotations, p.Content...)
+		}
+	}
+	require.Equal(t, []any{
+		map[string]any{"type": "usage", "promptTokens": int64(12), "completionTokens": int64(1)},
+		map[string]any{"type": "usage", "promptTokens": int64(12), "completionTokens": int64(6)},
+	}, annotations)
+	require.Equal(t, int64(6), acc.Usage().CompletionTokensOrZero())
+	require.Equal(t, "Hello!", acc.Messages()[0].Content)
+}
+
+func TestAnthropicToDataStream_StopSequence(t *testing.T) {
+	t.Parallel()
+
+	anthropicResponses := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_stop","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"<action>search"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"stop_sequence","stop_sequence":"</action>"},"usage":{"output_tokens":4}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+	})
+	typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+
+	var finishStep aisdk.FinishStepStreamPart
+	var acc aisdk.DataStreamAccumulator
+	for part, err := range aisdk.AnthropicToDataStream(typedStream).WithAccumulator(&acc) {
+		require.NoError(t, err)
+		if p, ok := part.(aisdk.FinishStepStreamPart); ok {
+			finishStep = p
+		}
+	}
+	require.Equal(t, aisdk.FinishReasonStop, finishStep.FinishReason)
+	require.Equal(t, "</action>", finishStep.StopSequence)
+	require.Equal(t, "</action>", acc.Steps()[0].StopSequence)
+
+	formatted, err := finishStep.Format()
+	require.NoError(t, err)
+	require.Contains(t, formatted, `"stopSequence":"\u003c/action\u003e"`)
+}
+
+func TestMessagesToAnthropic_Thinking(t *testing.T) {
+	t.Parallel()
+
+	anthropicResponses := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_thinking","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":"","signature":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"The user "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"said hi."}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"EqQBCgIYAhIM"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"redacted_thinking","data":"EmwKAhgBEgy3"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: content_block_start
+data: {"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Hello!"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":2}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":20}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+	})
+	typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range aisdk.AnthropicToDataStream(typedStream).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, "The user said hi.", acc.Messages()[0].ReasoningContent())
+
+	anthropicMsgs, _, err := aisdk.MessagesToAnthropic([]aisdk.Message{
+		{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Hi"}}},
+		acc.Messages()[0],
+	})
+	require.NoError(t, err)
+	require.Len(t, anthropicMsgs, 2)
+
+	content := anthropicMsgs[1].Content
+	require.Len(t, content, 3)
+	require.Equal(t, &anthropic.ThinkingBlockParam{
+		Thinking:  "The user said hi.",
+		Signature: "EqQBCgIYAhIM",
+	}, content[0].OfThinking)
+	require.Equal(t, &anthropic.RedactedThinkingBlockParam{Data: "EmwKAhgBEgy3"}, content[1].OfRedactedThinking)
+	require.Equal(t, "Hello!", content[2].OfText.Text)
+}
+
+func TestAnthropicToDataStream_Continuation(t *testing.T) {
+	t.Parallel()
+
+	response := func(id, text, stopReason string) aisdk.DataStream {
+		events := `event: message_start
+data: {"type":"message_start","message":{"id":"` + id + `","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"` + text + `"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"` + stopReason + `","stop_sequence":null},"usage":{"output_tokens":5}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+		decoder := ssestream.NewDecoder(&http.Response{
+			Body: io.NopCloser(strings.NewReader(events)),
+		})
+		return aisdk.AnthropicToDataStream(ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil))
+	}
+	withoutFinishMessage := func(part aisdk.DataStreamPart) bool {
+		_, ok := part.(aisdk.FinishMessageStreamPart)
+		return !ok
+	}
+
+	// The continuation request continues the cut off message.
+	var acc aisdk.DataStreamAccumulator
+	for part, err := range response("msg_1", "Once upon", "max_tokens").Filter(withoutFinishMessage) {
+		require.NoError(t, err)
+		if p, ok := part.(aisdk.FinishStepStreamPart); ok {
+			require.True(t, p.IsContinued)
+			require.Equal(t, aisdk.FinishReasonLength, p.FinishReason)
+		}
+		require.NoError(t, acc.Push(part))
+	}
+	for part, err := range response("msg_2", " a time.", "end_turn") {
+		require.NoError(t, err)
+		require.NoError(t, acc.Push(part))
+	}
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, "msg_1", acc.Messages()[0].ID)
+	require.Equal(t, "Once upon a time.", acc.Messages()[0].TextContent())
+
+	// A complete response finishes its message.
+	acc = aisdk.DataStreamAccumulator{}
+	for _, stream := range []aisdk.DataStream{
+		response("msg_1", "Hello.", "end_turn").Filter(withoutFinishMessage),
+		response("msg_2", "Hello again.", "end_turn"),
+	} {
+		for _, err := range stream.WithAccumulator(&acc) {
+			require.NoError(t, err)
+		}
+	}
+	require.Len(t, acc.Messages(), 2)
+}
+
+func TestAnthropicToDataStream_ThinkingThenToolCall(t *testing.T) {
+	t.Parallel()
+
+	anthropicResponses := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_think_tool","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":50,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":"","signature":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"I should check the weather."}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"EqQBCgIYAhIM"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"location\":\"Paris\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":30}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+	})
+	typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range aisdk.AnthropicToDataStream(typedStream).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.Len(t, acc.Messages(), 1)
+
+	parts := acc.Messages()[0].Parts
+	require.Len(t, parts, 3)
+	require.Equal(t, aisdk.PartTypeStepStart, parts[0].Type)
+	require.Equal(t, aisdk.PartTypeReasoning, parts[1].Type)
+	require.Equal(t, "I should check the weather.", parts[1].Reasoning)
+	require.Equal(t, aisdk.PartTypeToolInvocation, parts[2].Type)
+	require.Equal(t, aisdk.ToolInvocationStateCall, parts[2].ToolInvocation.State)
+	require.Equal(t, map[string]any{"location": "Paris"}, parts[2].ToolInvocation.Args)
+	require.Equal(t, aisdk.FinishReasonToolCalls, acc.FinishReason())
+}
+
+func TestAnthropicToDataStream_ContentBlockStop(t *testing.T) {
+	t.Parallel()
+
+	anthropicResponses := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_block_stop","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":50,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"location\":"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_2","name":"get_time","input":{}}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: content_block_start
+data: {"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Checking."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":2}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":30}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+	})
+	typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+
+	var calls []aisdk.ToolCall
+	var parts []aisdk.DataStreamPart
+	stream := aisdk.AnthropicToDataStream(typedStream).WithToolCalling(func(toolCall aisdk.ToolCall) any {
+		calls = append(calls, toolCall)
+		return "ok"
+	})
+	for part, err := range stream {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+
+	// Each tool call is handled once, including the one without arguments
+	// that is only complete once its block stops.
+	require.Equal(t, []aisdk.ToolCall{
+		{ID: "toolu_1", Name: "get_weather", Args: map[string]any{"location": "Paris"}},
+		{ID: "toolu_2", Name: "get_time", Args: map[string]any{}},
+	}, calls)
+
+	var toolCallParts []aisdk.ToolCallStreamPart
+	for _, part := range parts {
+		if p, ok := part.(aisdk.ToolCallStreamPart); ok {
+			toolCallParts = append(toolCallParts, p)
+		}
+	}
+	require.Len(t, toolCallParts, 2)
+
+	// Without tool calling, the stream has the consolidated call at the end
+	// of the block, before the text that follows.
+	decoder = ssestream.NewDecoder(&http.Response{
+		Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+	})
+	typedStream = ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+	parts = nil
+	for part, err := range aisdk.AnthropicToDataStream(typedStream) {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+	require.Equal(t, aisdk.ToolCallStreamPart{
+		ToolCallID: "toolu_1",
+		ToolName:   "get_weather",
+		Args:       map[string]any{"location": "Paris"},
+	}, parts[4])
+	require.Equal(t, aisdk.ToolCallStreamPart{
+		ToolCallID: "toolu_2",
+		ToolName:   "get_time",
+		Args:       map[string]any{},
+	}, parts[6])
+	require.Equal(t, aisdk.TextStreamPart{Content: "Checking."}, parts[7])
+}
+
+func TestAnthropicPrefill(t *testing.T) {
+	t.Parallel()
+
+	messages := []aisdk.Message{
+		{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Answer in JSON."}}},
+		{Role: "assistant", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "{ \n"}}},
+	}
+	anthropicMessages, _, err := aisdk.MessagesToAnthropic(messages)
+	require.NoError(t, err)
+	require.Len(t, anthropicMessages, 2)
+	require.Equal(t, anthropic.MessageParamRoleAssistant, anthropicMessages[1].Role)
+	require.Equal(t, "{", anthropicMessages[1].Content[0].OfText.Text)
+
+	anthropicResponses := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_prefill","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":20,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"\"answer\": 42}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":8}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+	})
+	typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range aisdk.AnthropicToDataStream(typedStream).WithPrefill(aisdk.Prefill(messages)).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, `{"answer": 42}`, acc.Messages()[0].Content)
+	require.Equal(t, `{"answer": 42}`, acc.Messages()[0].TextContent())
+}
+
+func TestMessagesToAnthropic_FileURL(t *testing.T) {
+	t.Parallel()
+
+	messages, _, err := aisdk.MessagesToAnthropic([]aisdk.Message{{
+		Role: "user",
+		Parts: []aisdk.Part{
+			{Type: aisdk.PartTypeFile, MimeType: "image/png", URL: "https://example.com/cat.png"},
+			{Type: aisdk.PartTypeFile, MimeType: "image/png", Data: []byte("png")},
+		},
+	}})
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	require.Len(t, messages[0].Content, 2)
+
+	urlSource := messages[0].Content[0].OfImage.Source
+	require.NotNil(t, urlSource.OfURL)
+	require.Equal(t, "https://example.com/cat.png", urlSource.OfURL.URL)
+
+	base64Source := messages[0].Content[1].OfImage.Source
+	require.NotNil(t, base64Source.OfBase64)
+	require.Equal(t, "cG5n", base64Source.OfBase64.Data)
+}
+
+func TestMessagesToAnthropic_PDF(t *testing.T) {
+	t.Parallel()
+
+	messages, _, err := aisdk.MessagesToAnthropic([]aisdk.Message{{
+		Role: "user",
+		Parts: []aisdk.Part{
+			{Type: aisdk.PartTypeText, Text: "Summarize this."},
+			{Type: aisdk.PartTypeFile, MimeType: "application/pdf", Data: []byte("pdf")},
+			{Type: aisdk.PartTypeFile, MimeType: "application/pdf", URL: "https://example.com/paper.pdf"},
+		},
+		Attachments: []aisdk.Attachment{{ContentType: "application/pdf", URL: "data:application/pdf;base64,cGRm"}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	require.Len(t, messages[0].Content, 4)
+
+	for _, block := range messages[0].Content[1:] {
+		require.Nil(t, block.OfImage)
+		require.NotNil(t, block.OfDocument)
+	}
+	require.Equal(t, "cGRm", messages[0].Content[1].OfDocument.Source.OfBase64.Data)
+	require.Equal(t, "https://example.com/paper.pdf", messages[0].Content[2].OfDocument.Source.OfURL.URL)
+	require.Equal(t, "cGRm", messages[0].Content[3].OfDocument.Source.OfBase64.Data)
+}
+
+func TestMessagesToAnthropic_URLAttachments(t *testing.T) {
+	t.Parallel()
+
+	messages, _, err := aisdk.MessagesToAnthropic([]aisdk.Message{{
+		Role: "user",
+		Attachments: []aisdk.Attachment{
+			{ContentType: "image/png", URL: "https://example.com/cat.png"},
+			{ContentType: "application/pdf", URL: "https://example.com/paper.pdf?a=1,2"},
+		},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/cat.png", messages[0].Content[0].OfImage.Source.OfURL.URL)
+	require.Equal(t, "https://example.com/paper.pdf?a=1,2", messages[0].Content[1].OfDocument.Source.OfURL.URL)
+
+	_, _, err = aisdk.MessagesToAnthropic([]aisdk.Message{{
+		Role:        "user",
+		Attachments: []aisdk.Attachment{{ContentType: "image/png", URL: "data:image/png,raw"}},
+	}})
+	require.EqualError(t, err, "invalid attachment URL: data:image/png,raw")
+}
+
+func TestMessagesToAnthropic_MultiPartToolResult(t *testing.T) {
+	t.Parallel()
+
+	messages, _, err := aisdk.MessagesToAnthropic([]aisdk.Message{{
+		Role: "assistant",
+		Parts: []aisdk.Part{{
+			Type: aisdk.PartTypeToolInvocation,
+			ToolInvocation: &aisdk.ToolInvocation{
+				State:      aisdk.ToolInvocationStateResult,
+				ToolCallID: "toolu_1",
+				ToolName:   "screenshot",
+				Args:       map[string]any{},
+				Result: aisdk.ToolResult(
+					aisdk.Part{Type: aisdk.PartTypeText, Text: "The login page."},
+					aisdk.Part{Type: aisdk.PartTypeFile, MimeType: "image/png", Data: []byte("png")},
+				),
+			},
+		}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	toolResult := messages[1].Content[0].OfToolResult
+	require.NotNil(t, toolResult)
+	require.Equal(t, "toolu_1", toolResult.ToolUseID)
+	require.Len(t, toolResult.Content, 2)
+	require.Equal(t, "The login page.", toolResult.Content[0].OfText.Text)
+	require.NotNil(t, toolResult.Content[1].OfImage)
+	require.Equal(t, "cG5n", toolResult.Content[1].OfImage.Source.OfBase64.Data)
+	require.False(t, toolResult.IsError.Valid())
+}
+
+func TestMessagesToAnthropic_PDFToolResult(t *testing.T) {
+	t.Parallel()
+
+	messages, _, err := aisdk.MessagesToAnthropic([]aisdk.Message{{
+		Role: "assistant",
+		Parts: []aisdk.Part{{
+			Type: aisdk.PartTypeToolInvocation,
+			ToolInvocation: &aisdk.ToolInvocation{
+				State:      aisdk.ToolInvocationStateResult,
+				ToolCallID: "toolu_1",
+				ToolName:   "download",
+				Args:       map[string]any{},
+				Result: aisdk.ToolResult(
+					aisdk.Part{Type: aisdk.PartTypeText, Text: "The paper."},
+					aisdk.Part{Type: aisdk.PartTypeFile, MimeType: "application/pdf", Data: []byte("pdf")},
+				),
+			},
+		}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	// The PDF follows the tool result, which can't hold documents.
+	require.Len(t, messages[1].Content, 2)
+	toolResult := messages[1].Content[0].OfToolResult
+	require.NotNil(t, toolResult)
+	require.Len(t, toolResult.Content, 1)
+	require.Equal(t, "The paper.", toolResult.Content[0].OfText.Text)
+	require.Equal(t, "cGRm", messages[1].Content[1].OfDocument.Source.OfBase64.Data)
+}
+
+func TestMessagesToAnthropic_ErrorToolResult(t *testing.T) {
+	t.Parallel()
+
+	messages, _, err := aisdk.MessagesToAnthropic([]aisdk.Message{{
+		Role: "assistant",
+		Parts: []aisdk.Part{{
+			Type: aisdk.PartTypeToolInvocation,
+			ToolInvocation: &aisdk.ToolInvocation{
+				State:      aisdk.ToolInvocationStateResult,
+				ToolCallID: "toolu_2",
+				ToolName:   "screenshot",
+				Args:       map[string]any{},
+				Result:     "browser crashed",
+				IsError:    true,
+			},
+		}},
+	}})
+	require.NoError(t, err)
+	require.True(t, messages[1].Content[0].OfToolResult.IsError.Value)
+}
+
+func TestMessagesToAnthropic_OnlySystem(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := aisdk.MessagesToAnthropic([]aisdk.Message{{
+		Role:  "system",
+		Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "You are a helpful assistant."}},
+	}})
+	require.EqualError(t, err, "at least one non-system message required")
+}
+
+func TestMessagesToAnthropic_UnsupportedImageType(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := aisdk.MessagesToAnthropic([]aisdk.Message{{
+		Role:  "user",
+		Parts: []aisdk.Part{{Type: aisdk.PartTypeFile, MimeType: "image/bmp", Data: []byte("bmp")}},
+	}})
+	require.EqualError(t, err, `unsupported image type "image/bmp" for Anthropic, supported types are image/jpeg, image/png, image/gif, image/webp`)
+}
+
+func TestAnthropicToDataStream_Error(t *testing.T) {
+	t.Parallel()
+
+	setupErr := errors.New("401 Unauthorized")
+	typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](nil, setupErr)
+
+	var streamErr error
+	for _, err := range aisdk.AnthropicToDataStream(typedStream) {
+		if err != nil {
+			streamErr = err
+		}
+	}
+
+	var providerErr *aisdk.ProviderError
+	require.ErrorAs(t, streamErr, &providerErr)
+	require.Equal(t, "anthropic", providerErr.Provider)
+	require.ErrorIs(t, streamErr, setupErr)
+}
+
+func TestAnthropicToDataStream_ErrorEvent(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		errorType string
+		rateLimit bool
+	}{
+		{"overloaded_error", false},
+		{"rate_limit_error", true},
+	} {
+		anthropicResponses := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}
+
+event: error
+data: {"type":"error","error":{"type":"` + tc.errorType + `","message":"Try again later"}}
+
+`
+		decoder := ssestream.NewDecoder(&http.Response{
+			Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+		})
+		typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+
+		var text string
+		var streamErr error
+		for part, err := range aisdk.AnthropicToDataStream(typedStream) {
+			if err != nil {
+				streamErr = err
+				continue
+			}
+			if p, ok := part.(aisdk.TextStreamPart); ok {
+				text += p.Content
+			}
+		}
+		require.Equal(t, "Hel", text)
+
+		var providerErr *aisdk.ProviderError
+		require.ErrorAs(t, streamErr, &providerErr)
+		var anthropicErr *aisdk.AnthropicStreamError
+		require.ErrorAs(t, streamErr, &anthropicErr)
+		require.Equal(t, tc.errorType, anthropicErr.Type)
+		require.Equal(t, "Try again later", anthropicErr.Message)
+		require.Equal(t, tc.errorType == "overloaded_error", anthropicErr.Overloaded())
+		var rateLimitErr *aisdk.RateLimitError
+		require.Equal(t, tc.rateLimit, errors.As(streamErr, &rateLimitErr))
+	}
+}
+
+func TestAnthropicResponseToMessage(t *testing.T) {
+	t.Parallel()
+
+	var resp anthropic.Message
+	err := json.Unmarshal([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"thinking","thinking":"The user wants a print.","signature":"EqQB"},{"type":"text","text":"Let me print that."},{"type":"tool_use","id":"toolu_1","name":"print","input":{"message":"Hello"}}],"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":20,"output_tokens":10}}`), &resp)
+	require.NoError(t, err)
+
+	message, err := aisdk.AnthropicResponseToMessage(resp)
+	require.NoError(t, err)
+	require.Equal(t, "msg_1", message.ID)
+	require.Equal(t, "Let me print that.", message.TextContent())
+	require.Equal(t, "The user wants a print.", message.ReasoningContent())
+
+	var invocation *aisdk.ToolInvocation
+	for _, part := range message.Parts {
+		if part.Type == aisdk.PartTypeToolInvocation {
+			invocation = part.ToolInvocation
+		}
+	}
+	require.Equal(t, &aisdk.ToolInvocation{
+		State:      aisdk.ToolInvocationStateCall,
+		Step:       intPtr(0),
+		ToolCallID: "toolu_1",
+		ToolName:   "print",
+		Args:       map[string]any{"message": "Hello"},
+	}, invocation)
+}
+
+func TestAnthropicBatch(t *testing.T) {
+	t.Parallel()
+
+	chats := []aisdk.Chat{{
+		ID: "chat_1",
+		Messages: []aisdk.Message{
+			{Role: "system", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Answer briefly."}}},
+			{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Hi"}}},
+		},
+	}, {
+		ID:       "chat_2",
+		Messages: []aisdk.Message{{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Bye"}}}},
+	}}
+
+	requests, err := aisdk.AnthropicBatchRequests(chats, anthropic.MessageBatchNewParamsRequestParams{
+		Model:     "claude-sonnet-4-20250514",
+		MaxTokens: 1024,
+	})
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	require.Equal(t, "chat_1", requests[0].CustomID)
+	require.Len(t, requests[0].Params.System, 1)
+	require.Len(t, requests[0].Params.Messages, 1)
+	require.Equal(t, int64(1024), requests[1].Params.MaxTokens)
+	require.Empty(t, requests[1].Params.System)
+
+	_, err = aisdk.AnthropicBatchRequests([]aisdk.Chat{chats[0], chats[0]}, anthropic.MessageBatchNewParamsRequestParams{})
+	require.ErrorContains(t, err, "duplicate chat ID")
+
+	results := `{"custom_id":"chat_1","result":{"type":"succeeded","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"Hello!"}],"stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":2}}}}
+{"custom_id":"chat_2","result":{"type":"errored","error":{"type":"error","request_id":"req_1","error":{"type":"invalid_request_error","message":"max_tokens is too large"}}}}
+{"custom_id":"chat_3","result":{"type":"expired"}}`
+
+	var messages []aisdk.Message
+	var errs []string
+	for _, line := range strings.Split(results, "\n") {
+		var resp anthropic.MessageBatchIndividualResponse
+		require.NoError(t, json.Unmarshal([]byte(line), &resp))
+		message, err := aisdk.AnthropicBatchResultToMessage(resp)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		messages = append(messages, message)
+	}
+	require.Len(t, messages, 1)
+	require.Equal(t, "Hello!", messages[0].TextContent())
+	require.Equal(t, []string{
+		`batch request "chat_2" errored: invalid_request_error: max_tokens is too large`,
+		`batch request "chat_3" was not processed: expired`,
+	}, errs)
+}
+
+func TestToolsToAnthropic_Cache(t *testing.T) {
+	t.Parallel()
+
+	tools := []aisdk.Tool{{
+		Name:        "get_weather",
+		Description: "Get the weather for a location.",
+		Schema: aisdk.Schema{
+			Required:   []string{"location"},
+			Properties: map[string]any{"location": map[string]any{"type": "string"}},
+		},
+	}, {
+		Name:        "get_time",
+		Description: "Get the time for a location.",
+		Schema: aisdk.Schema{
+			Required:   []string{"location"},
+			Properties: map[string]any{"location": map[string]any{"type": "string"}},
+		},
+	}}
+
+	uncached := aisdk.ToolsToAnthropic(tools)
+	for _, tool := range uncached {
+		data, err := json.Marshal(tool)
+		require.NoError(t, err)
+		require.NotContains(t, string(data), "cache_control")
+	}
+
+	cached := aisdk.ToolsToAnthropic(tools, aisdk.WithToolsCache())
+	require.Len(t, cached, 2)
+
+	first, err := json.Marshal(cached[0])
+	require.NoError(t, err)
+	require.NotContains(t, string(first), "cache_control")
+
+	last, err := json.Marshal(cached[1])
+	require.NoError(t, err)
+	require.Contains(t, string(last), `"cache_control":{"type":"ephemeral"}`)
+	require.Contains(t, string(last), `"required":["location"]`)
+
+	require.Empty(t, aisdk.ToolsToAnthropic(nil, aisdk.WithToolsCache()))
+}
+
+func TestThinkingBudgetToAnthropic(t *testing.T) {
+	t.Parallel()
+
+	thinking, err := aisdk.ThinkingBudgetToAnthropic(2048, 4096)
+	require.NoError(t, err)
+	require.NotNil(t, thinking.OfEnabled)
+	require.Equal(t, int64(2048), thinking.OfEnabled.BudgetTokens)
+
+	thinking, err = aisdk.ThinkingBudgetToAnthropic(0, 4096)
+	require.NoError(t, err)
+	require.NotNil(t, thinking.OfDisabled)
+
+	_, err = aisdk.ThinkingBudgetToAnthropic(512, 4096)
+	require.ErrorContains(t, err, "minimum of 1024")
+
+	_, err = aisdk.ThinkingBudgetToAnthropic(4096, 4096)
+	require.ErrorContains(t, err, "less than max tokens")
+}
+
+func TestAnthropicToDataStream_InvalidToolArgs(t *testing.T) {
+	t.Parallel()
+
+	anthropicResponses := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"time","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"zone\":"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+`
+	decoder := ssestream.NewDecoder(&http.Response{
+		Body: io.NopCloser(strings.NewReader(anthropicResponses)),
+	})
+	typedStream := ssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil)
+
+	err := aisdk.AnthropicToDataStream(typedStream).Drain()
+	var providerErr *aisdk.ProviderError
+	require.True(t, errors.As(err, &providerErr))
+	require.Equal(t, "anthropic", providerErr.Provider)
+	require.ErrorContains(t, err, "failed to parse arguments for tool call toolu_1")
+}
+
+func TestRateLimitError_Anthropic(t *testing.T) {
+	t.Parallel()
+
+	server := newRateLimitServer(t, "Retry-After-Ms", "1500")
+	client := anthropic.NewClient(
+		option.WithBaseURL(server.URL),
+		option.WithAPIKey("sk-test"),
+		option.WithMaxRetries(0),
+	)
+	stream := client.Messages.NewStreaming(context.Background(), anthropic.MessageNewParams{
+		Model:     "claude-sonnet-4-20250514",
+		MaxTokens: 1024,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("Hello"))},
+	})
+
+	var streamErr error
+	for _, err := range aisdk.AnthropicToDataStream(stream) {
+		streamErr = err
+	}
+
+	var rateLimitErr *aisdk.RateLimitError
+	require.ErrorAs(t, streamErr, &rateLimitErr)
+	require.Equal(t, 1500*time.Millisecond, rateLimitErr.RetryAfter)
+}
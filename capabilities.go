@@ -0,0 +1,67 @@
+package aisdk
+
+import "strings"
+
+// ProviderCapabilities describes what a model accepts and produces, so that
+// requests can be routed to a compatible model.
+type ProviderCapabilities struct {
+	// Image is whether the model accepts image input.
+	Image bool
+	// PDF is whether the model accepts PDF documents.
+	PDF bool
+	// Audio is whether the model accepts audio input.
+	Audio bool
+	// Reasoning is whether the model can reason before it responds.
+	Reasoning bool
+	// MaxContextTokens is the size of the context window in tokens.
+	MaxContextTokens int64
+}
+
+// modelCapabilities are the capabilities of a model family, matched by the
+// prefix of the model name.
+type modelCapabilities struct {
+	provider     string
+	prefix       string
+	capabilities ProviderCapabilities
+}
+
+// knownModels is checked in order, so more specific prefixes come first.
+var knownModels = []modelCapabilities{
+	{"anthropic", "claude-opus-4", ProviderCapabilities{Image: true, PDF: true, Reasoning: true, MaxContextTokens: 200_000}},
+	{"anthropic", "claude-sonnet-4", ProviderCapabilities{Image: true, PDF: true, Reasoning: true, MaxContextTokens: 200_000}},
+	{"anthropic", "claude-3-7-sonnet", ProviderCapabilities{Image: true, PDF: true, Reasoning: true, MaxContextTokens: 200_000}},
+	{"anthropic", "claude-3-5-sonnet", ProviderCapabilities{Image: true, PDF: true, MaxContextTokens: 200_000}},
+	{"anthropic", "claude-3-5-haiku", ProviderCapabilities{Image: true, PDF: true, MaxContextTokens: 200_000}},
+	{"anthropic", "claude-3", ProviderCapabilities{Image: true, MaxContextTokens: 200_000}},
+
+	{"openai", "gpt-4o-audio", ProviderCapabilities{Audio: true, MaxContextTokens: 128_000}},
+	{"openai", "gpt-4o", ProviderCapabilities{Image: true, PDF: true, MaxContextTokens: 128_000}},
+	{"openai", "gpt-4.1", ProviderCapabilities{Image: true, PDF: true, MaxContextTokens: 1_047_576}},
+	{"openai", "gpt-5", ProviderCapabilities{Image: true, PDF: true, Reasoning: true, MaxContextTokens: 400_000}},
+	{"openai", "o1-mini", ProviderCapabilities{Reasoning: true, MaxContextTokens: 128_000}},
+	{"openai", "o3-mini", ProviderCapabilities{Reasoning: true, MaxContextTokens: 200_000}},
+	{"openai", "o1", ProviderCapabilities{Image: true, PDF: true, Reasoning: true, MaxContextTokens: 200_000}},
+	{"openai", "o3", ProviderCapabilities{Image: true, PDF: true, Reasoning: true, MaxContextTokens: 200_000}},
+	{"openai", "o4-mini", ProviderCapabilities{Image: true, PDF: true, Reasoning: true, MaxContextTokens: 200_000}},
+
+	{"perplexity", "sonar-reasoning", ProviderCapabilities{Reasoning: true, MaxContextTokens: 128_000}},
+	{"perplexity", "sonar-deep-research", ProviderCapabilities{Reasoning: true, MaxContextTokens: 128_000}},
+	{"perplexity", "sonar-pro", ProviderCapabilities{MaxContextTokens: 200_000}},
+	{"perplexity", "sonar", ProviderCapabilities{MaxContextTokens: 128_000}},
+}
+
+// Capabilities returns the capabilities of a model from a static table of
+// known model families, e.g. Capabilities("anthropic", "claude-sonnet-4-20250514").
+// The provider is named like in ProviderError. Unknown models report no
+// capabilities and a zero MaxContextTokens.
+//
+// The table describes the models, not the converters: MessagesToOpenAI, for
+// example, only sends images.
+func Capabilities(provider string, model string) ProviderCapabilities {
+	for _, known := range knownModels {
+		if known.provider == provider && strings.HasPrefix(model, known.prefix) {
+			return known.capabilities
+		}
+	}
+	return ProviderCapabilities{}
+}
@@ -0,0 +1,29 @@
+package aisdk_test
+
+import (
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities(t *testing.T) {
+	t.Parallel()
+
+	sonnet := aisdk.Capabilities("anthropic", "claude-sonnet-4-20250514")
+	require.True(t, sonnet.Image)
+	require.True(t, sonnet.PDF)
+	require.True(t, sonnet.Reasoning)
+	require.Equal(t, int64(200_000), sonnet.MaxContextTokens)
+
+	// More specific families take precedence over their prefix.
+	require.False(t, aisdk.Capabilities("anthropic", "claude-3-opus-20240229").PDF)
+	require.True(t, aisdk.Capabilities("openai", "gpt-4o-audio-preview").Audio)
+	require.False(t, aisdk.Capabilities("openai", "gpt-4o-mini").Audio)
+	require.False(t, aisdk.Capabilities("openai", "o3-mini").Image)
+	require.True(t, aisdk.Capabilities("openai", "o3").Image)
+	require.True(t, aisdk.Capabilities("perplexity", "sonar-reasoning-pro").Reasoning)
+
+	require.Equal(t, aisdk.ProviderCapabilities{}, aisdk.Capabilities("openai", "claude-sonnet-4-20250514"))
+	require.Equal(t, aisdk.ProviderCapabilities{}, aisdk.Capabilities("unknown", "model"))
+}
@@ -0,0 +1,76 @@
+package aisdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Client talks to a server that streams the v1 data stream protocol, e.g. one
+// that pipes a DataStream with Pipe, so both ends of a chat can be written in
+// Go:
+//
+//	client := aisdk.Client{URL: "https://example.com/api/chat"}
+//	stream, err := client.Chat(ctx, chat)
+//	if err != nil {
+//		return err
+//	}
+//	var acc aisdk.DataStreamAccumulator
+//	for _, err := range stream.WithAccumulator(&acc) {
+//		...
+//	}
+type Client struct {
+	// URL is the chat endpoint, like the `api` option of `useChat`.
+	URL string
+	// HTTPClient sends the requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// Header is added to every request, e.g. for authorization.
+	Header http.Header
+}
+
+// Chat POSTs the chat to the server and returns the streamed response.
+// The response body is closed once the stream is iterated to the end, or the
+// consumer stops early, so the stream must be iterated once.
+func (c *Client) Chat(ctx context.Context, chat Chat) (DataStream, error) {
+	body, err := json.Marshal(chat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat request: %w", err)
+	}
+	for key, values := range c.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send chat request: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("chat request failed with status %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	return func(yield func(DataStreamPart, error) bool) {
+		defer resp.Body.Close()
+		for part, err := range ParseDataStream(resp.Body) {
+			if !yield(part, err) {
+				return
+			}
+		}
+	}, nil
+}
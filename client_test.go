@@ -0,0 +1,56 @@
+package aisdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Chat(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var chat aisdk.Chat
+		if err := json.NewDecoder(r.Body).Decode(&chat); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		aisdk.WriteDataStreamHeaders(w)
+		_ = partsStream(
+			aisdk.StartStepStreamPart{MessageID: "msg_1"},
+			aisdk.TextStreamPart{Content: "You said: "},
+			aisdk.TextStreamPart{Content: chat.Messages[0].Content},
+			aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+			aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+		).Pipe(w)
+	}))
+	defer server.Close()
+
+	chat := aisdk.Chat{ID: "chat_1", Messages: []aisdk.Message{{Role: "user", Content: "Hi"}}}
+
+	client := aisdk.Client{URL: server.URL, Header: http.Header{"Authorization": {"Bearer token"}}}
+	stream, err := client.Chat(context.Background(), chat)
+	require.NoError(t, err)
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range stream.WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, "You said: Hi", acc.Messages()[0].Content)
+	require.Equal(t, aisdk.FinishReasonStop, acc.FinishReason())
+
+	unauthorized := aisdk.Client{URL: server.URL}
+	_, err = unauthorized.Chat(context.Background(), chat)
+	require.ErrorContains(t, err, "401 Unauthorized: unauthorized")
+}
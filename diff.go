@@ -0,0 +1,181 @@
+package aisdk
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// MessagesPatch is the difference between two versions of a message list,
+// e.g. to push only what changed to the clients of a shared conversation.
+// Apply turns the old list into the new one.
+type MessagesPatch struct {
+	// Keep is the number of messages of the old list that are kept.
+	Keep int `json:"keep"`
+	// Truncated is whether the old list had messages after the kept ones,
+	// which were removed or changed in a way that isn't incremental, like an
+	// edited user message. They are replaced by Appended.
+	Truncated bool `json:"truncated,omitempty"`
+	// Updated are the incremental changes to kept messages.
+	Updated []MessagePatch `json:"updated,omitempty"`
+	// Appended are the messages after the kept ones.
+	Appended []Message `json:"appended,omitempty"`
+}
+
+// MessagePatch is an incremental change to a message, like a streaming
+// response that grows.
+type MessagePatch struct {
+	// Index is the index of the message in the list.
+	Index int `json:"index"`
+	// ContentDelta is appended to the content of the message.
+	ContentDelta string `json:"contentDelta,omitempty"`
+	// Parts are changes to existing parts of the message.
+	Parts []PartPatch `json:"parts,omitempty"`
+	// AppendedParts are appended to the parts of the message.
+	AppendedParts []Part `json:"appendedParts,omitempty"`
+	// AppendedAnnotations are appended to the annotations of the message.
+	AppendedAnnotations []any `json:"appendedAnnotations,omitempty"`
+}
+
+// PartPatch is a change to an existing part of a message. Either TextDelta
+// or Part is set.
+type PartPatch struct {
+	// Index is the index of the part in the message.
+	Index int `json:"index"`
+	// TextDelta is appended to the text of a text part, or the reasoning of a
+	// reasoning part.
+	TextDelta string `json:"textDelta,omitempty"`
+	// Part replaces the part, e.g. a tool invocation that got its result.
+	Part *Part `json:"part,omitempty"`
+}
+
+// IsEmpty returns whether the patch doesn't change anything.
+func (p MessagesPatch) IsEmpty() bool {
+	return !p.Truncated && len(p.Updated) == 0 && len(p.Appended) == 0
+}
+
+// DiffMessages returns the patch that turns oldMessages into newMessages.
+// Messages are compared by position. A message that only grew, by text
+// appended to its content or parts, new parts, tool invocations changing
+// state or new annotations, is patched in place. Any other change, or a
+// message with another ID or role, replaces it and every message after it.
+func DiffMessages(oldMessages, newMessages []Message) MessagesPatch {
+	var patch MessagesPatch
+	for i := 0; i < len(oldMessages) && i < len(newMessages); i++ {
+		messagePatch, ok := diffMessage(oldMessages[i], newMessages[i])
+		if !ok {
+			break
+		}
+		patch.Keep = i + 1
+		if messagePatch != nil {
+			messagePatch.Index = i
+			patch.Updated = append(patch.Updated, *messagePatch)
+		}
+	}
+	patch.Truncated = patch.Keep < len(oldMessages)
+	if patch.Keep < len(newMessages) {
+		patch.Appended = newMessages[patch.Keep:]
+	}
+	return patch
+}
+
+// diffMessage returns the incremental change from oldMessage to newMessage,
+// nil if they are equal, and false if the change isn't incremental.
+func diffMessage(oldMessage, newMessage Message) (*MessagePatch, bool) {
+	if !strings.HasPrefix(newMessage.Content, oldMessage.Content) ||
+		len(newMessage.Parts) < len(oldMessage.Parts) ||
+		len(newMessage.Annotations) < len(oldMessage.Annotations) ||
+		!slices.EqualFunc(newMessage.Annotations[:len(oldMessage.Annotations)], oldMessage.Annotations, func(a, b any) bool {
+			return reflect.DeepEqual(a, b)
+		}) {
+		return nil, false
+	}
+	// Everything else must be unchanged.
+	oldRest, newRest := oldMessage, newMessage
+	oldRest.Content, oldRest.Parts, oldRest.Annotations = "", nil, nil
+	newRest.Content, newRest.Parts, newRest.Annotations = "", nil, nil
+	if !reflect.DeepEqual(oldRest, newRest) {
+		return nil, false
+	}
+
+	patch := MessagePatch{
+		ContentDelta: newMessage.Content[len(oldMessage.Content):],
+	}
+	if len(newMessage.Parts) > len(oldMessage.Parts) {
+		patch.AppendedParts = newMessage.Parts[len(oldMessage.Parts):]
+	}
+	if len(newMessage.Annotations) > len(oldMessage.Annotations) {
+		patch.AppendedAnnotations = newMessage.Annotations[len(oldMessage.Annotations):]
+	}
+	for i, oldPart := range oldMessage.Parts {
+		newPart := newMessage.Parts[i]
+		if reflect.DeepEqual(oldPart, newPart) {
+			continue
+		}
+		if oldPart.Type != newPart.Type {
+			return nil, false
+		}
+		if delta, ok := textDelta(oldPart, newPart); ok {
+			patch.Parts = append(patch.Parts, PartPatch{Index: i, TextDelta: delta})
+			continue
+		}
+		patch.Parts = append(patch.Parts, PartPatch{Index: i, Part: &newPart})
+	}
+	if patch.ContentDelta == "" && len(patch.Parts) == 0 && len(patch.AppendedParts) == 0 && len(patch.AppendedAnnotations) == 0 {
+		return nil, true
+	}
+	return &patch, true
+}
+
+// textDelta returns the text appended to a text or reasoning part, and false
+// if the part changed in another way.
+func textDelta(oldPart, newPart Part) (string, bool) {
+	grown := oldPart
+	var oldText, newText string
+	switch oldPart.Type {
+	case PartTypeText:
+		oldText, newText = oldPart.Text, newPart.Text
+		grown.Text = newText
+	case PartTypeReasoning:
+		oldText, newText = oldPart.Reasoning, newPart.Reasoning
+		grown.Reasoning = newText
+	default:
+		return "", false
+	}
+	if !strings.HasPrefix(newText, oldText) || !reflect.DeepEqual(grown, newPart) {
+		return "", false
+	}
+	return newText[len(oldText):], true
+}
+
+// Apply returns the messages with the patch applied. The messages aren't
+// modified.
+func (p MessagesPatch) Apply(messages []Message) []Message {
+	result := slices.Clone(messages[:min(p.Keep, len(messages))])
+	for _, update := range p.Updated {
+		if update.Index >= len(result) {
+			continue
+		}
+		message := result[update.Index]
+		message.Content += update.ContentDelta
+		message.Parts = slices.Clone(message.Parts)
+		for _, partPatch := range update.Parts {
+			if partPatch.Index >= len(message.Parts) {
+				continue
+			}
+			part := &message.Parts[partPatch.Index]
+			switch {
+			case partPatch.Part != nil:
+				*part = *partPatch.Part
+			case part.Type == PartTypeReasoning:
+				part.Reasoning += partPatch.TextDelta
+			default:
+				part.Text += partPatch.TextDelta
+			}
+		}
+		message.Parts = append(message.Parts, update.AppendedParts...)
+		message.Annotations = append(slices.Clone(message.Annotations), update.AppendedAnnotations...)
+		result[update.Index] = message
+	}
+	return append(result, p.Appended...)
+}
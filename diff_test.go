@@ -0,0 +1,61 @@
+package aisdk_test
+
+import (
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffMessages(t *testing.T) {
+	t.Parallel()
+
+	user := aisdk.Message{ID: "msg_1", Role: "user", Content: "Weather in Paris?", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Weather in Paris?"}}}
+	streaming := aisdk.Message{ID: "msg_2", Role: "assistant", Parts: []aisdk.Part{
+		{Type: aisdk.PartTypeStepStart},
+		{Type: aisdk.PartTypeText, Text: "Let me "},
+		{Type: aisdk.PartTypeToolInvocation, ToolInvocation: &aisdk.ToolInvocation{State: aisdk.ToolInvocationStateCall, ToolCallID: "call_1", ToolName: "weather"}},
+	}}
+	done := aisdk.Message{ID: "msg_2", Role: "assistant", Parts: []aisdk.Part{
+		{Type: aisdk.PartTypeStepStart},
+		{Type: aisdk.PartTypeText, Text: "Let me check."},
+		{Type: aisdk.PartTypeToolInvocation, ToolInvocation: &aisdk.ToolInvocation{State: aisdk.ToolInvocationStateResult, ToolCallID: "call_1", ToolName: "weather", Result: "sunny"}},
+		{Type: aisdk.PartTypeText, Text: "It's sunny."},
+	}, Annotations: []any{"done"}}
+
+	oldMessages := []aisdk.Message{user, streaming}
+	newMessages := []aisdk.Message{user, done}
+	patch := aisdk.DiffMessages(oldMessages, newMessages)
+	require.Equal(t, aisdk.MessagesPatch{
+		Keep: 2,
+		Updated: []aisdk.MessagePatch{{
+			Index: 1,
+			Parts: []aisdk.PartPatch{
+				{Index: 1, TextDelta: "check."},
+				{Index: 2, Part: &done.Parts[2]},
+			},
+			AppendedParts:       []aisdk.Part{{Type: aisdk.PartTypeText, Text: "It's sunny."}},
+			AppendedAnnotations: []any{"done"},
+		}},
+	}, patch)
+	require.Equal(t, newMessages, patch.Apply(oldMessages))
+	require.Equal(t, streaming.Parts[1].Text, "Let me ", "Apply must not modify the old messages")
+
+	// Appended messages.
+	patch = aisdk.DiffMessages(oldMessages[:1], newMessages)
+	require.Equal(t, aisdk.MessagesPatch{Keep: 1, Appended: []aisdk.Message{done}}, patch)
+
+	// An edited user message replaces everything after it.
+	edited := user
+	edited.Content = "Weather in Rome?"
+	patch = aisdk.DiffMessages(newMessages, []aisdk.Message{edited})
+	require.Equal(t, aisdk.MessagesPatch{Keep: 0, Truncated: true, Appended: []aisdk.Message{edited}}, patch)
+	require.Equal(t, []aisdk.Message{edited}, patch.Apply(newMessages))
+
+	// Removed messages.
+	patch = aisdk.DiffMessages(newMessages, newMessages[:1])
+	require.False(t, patch.IsEmpty())
+	require.Equal(t, newMessages[:1], patch.Apply(newMessages))
+
+	require.True(t, aisdk.DiffMessages(newMessages, newMessages).IsEmpty())
+}
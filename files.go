@@ -0,0 +1,58 @@
+package aisdk
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// FileMessagePart reads the file at path into a file Part, e.g. to attach a
+// local image or PDF to a user message. The MIME type is detected from the
+// content, falling back to the extension for content like plain text that
+// can't be told apart by sniffing. The data is base64-encoded by the
+// converters of providers that need it.
+func FileMessagePart(path string) (Part, error) {
+	data, mimeType, err := readFile(path)
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{
+		Type:     PartTypeFile,
+		MimeType: mimeType,
+		Data:     data,
+	}, nil
+}
+
+// AttachmentFromFile reads the file at path into an Attachment with a base64
+// data URL, named after the file. The MIME type is detected like in
+// FileMessagePart.
+func AttachmentFromFile(path string) (Attachment, error) {
+	data, mimeType, err := readFile(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return Attachment{
+		Name:        filepath.Base(path),
+		ContentType: mimeType,
+		URL:         fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)),
+	}, nil
+}
+
+// readFile returns the content and MIME type of the file at path, without
+// parameters like the charset.
+func readFile(path string) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	mimeType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if mimeType == "application/octet-stream" || mimeType == "text/plain" {
+		if byExtension, _, err := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(path))); err == nil {
+			mimeType = byExtension
+		}
+	}
+	return data, mimeType, nil
+}
@@ -0,0 +1,51 @@
+package aisdk_test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMessagePart(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	// The content wins over a misleading extension.
+	pngPath := filepath.Join(dir, "chart.bin")
+	require.NoError(t, os.WriteFile(pngPath, png, 0o600))
+	jsonPath := filepath.Join(dir, "data.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"a":1}`), 0o600))
+
+	part, err := aisdk.FileMessagePart(pngPath)
+	require.NoError(t, err)
+	require.Equal(t, aisdk.Part{Type: aisdk.PartTypeFile, MimeType: "image/png", Data: png}, part)
+
+	part, err = aisdk.FileMessagePart(jsonPath)
+	require.NoError(t, err)
+	require.Equal(t, "application/json", part.MimeType)
+
+	_, err = aisdk.FileMessagePart(filepath.Join(dir, "missing.png"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestAttachmentFromFile(t *testing.T) {
+	t.Parallel()
+
+	pdf := []byte("%PDF-1.7\n")
+	path := filepath.Join(t.TempDir(), "report.pdf")
+	require.NoError(t, os.WriteFile(path, pdf, 0o600))
+
+	attachment, err := aisdk.AttachmentFromFile(path)
+	require.NoError(t, err)
+	require.Equal(t, aisdk.Attachment{
+		Name:        "report.pdf",
+		ContentType: "application/pdf",
+		URL:         "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(pdf),
+	}, attachment)
+	require.NoError(t, aisdk.Chat{Messages: []aisdk.Message{{Role: "user", Attachments: []aisdk.Attachment{attachment}}}}.Validate())
+}
@@ -0,0 +1,13 @@
+package aisdk
+
+import (
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// FireworksToDataStream pipes a Fireworks stream to a DataStream.
+// Fireworks sends usage with the finish reason, even without
+// stream_options.include_usage.
+func FireworksToDataStream(stream *ssestream.Stream[openai.ChatCompletionChunk]) DataStream {
+	return openAIToDataStream("fireworks", stream)
+}
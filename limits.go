@@ -0,0 +1,152 @@
+package aisdk
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Limits bounds the size of a Chat decoded by DecodeChat.
+// A zero value disables the respective limit.
+type Limits struct {
+	// MaxBodyBytes is the maximum size of the request body.
+	MaxBodyBytes int64
+	// MaxMessages is the maximum number of messages in the chat.
+	MaxMessages int
+	// MaxAttachmentBytes is the maximum size of a single attachment or file
+	// part. The size of an attachment is the length of its (data) URL.
+	MaxAttachmentBytes int64
+}
+
+// DefaultLimits are reasonable limits for a chat request.
+var DefaultLimits = Limits{
+	MaxBodyBytes:       20 << 20,
+	MaxMessages:        1000,
+	MaxAttachmentBytes: 10 << 20,
+}
+
+// LimitError is returned by DecodeChat when the chat exceeds a limit.
+type LimitError struct {
+	// Limit is the name of the exceeded field of Limits.
+	Limit string
+	// Max is the value of the exceeded limit.
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("chat exceeds %s of %d", e.Limit, e.Max)
+}
+
+// AttachmentError is returned by Chat.Validate for a malformed attachment.
+type AttachmentError struct {
+	// MessageIndex is the index of the message in the chat.
+	MessageIndex int
+	// AttachmentIndex is the index of the attachment in the message.
+	AttachmentIndex int
+	Err             error
+}
+
+func (e *AttachmentError) Error() string {
+	return fmt.Sprintf("invalid attachment %d of message %d: %s", e.AttachmentIndex, e.MessageIndex, e.Err)
+}
+
+func (e *AttachmentError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that the URLs of the attachments of the chat have a form the
+// provider converters accept: each URL is a base64 data URL with a content
+// type, or an http(s) URL. Malformed attachments are returned as an
+// *AttachmentError. The content type is checked by the converters, since the
+// supported types depend on the provider.
+func (c Chat) Validate() error {
+	for i, message := range c.Messages {
+		for j, attachment := range message.Attachments {
+			if err := validateAttachment(attachment); err != nil {
+				return &AttachmentError{MessageIndex: i, AttachmentIndex: j, Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+func validateAttachment(attachment Attachment) error {
+	if rest, ok := strings.CutPrefix(attachment.URL, "data:"); ok {
+		header, data, ok := strings.Cut(rest, ",")
+		if !ok {
+			return errors.New("data URL has no data")
+		}
+		// The providers take the content type of inlined data from the
+		// attachment, not the URL.
+		if attachment.ContentType == "" {
+			return errors.New("content type is required for data URLs")
+		}
+		// The providers only take inlined data as base64.
+		if !strings.HasSuffix(header, ";base64") {
+			return errors.New("data URL must be base64-encoded")
+		}
+		if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+			return fmt.Errorf("data URL is not valid base64: %w", err)
+		}
+		return nil
+	}
+	u, err := url.Parse(attachment.URL)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("URL must be a data URL or an http(s) URL, got %q", attachment.URL)
+	}
+	return nil
+}
+
+// DecodeChat decodes a Chat sent from `useChat`, enforcing the limits before
+// the chat is converted for a provider. Exceeded limits are returned as a
+// *LimitError, and malformed attachments as an *AttachmentError.
+func DecodeChat(r io.Reader, limits Limits) (Chat, error) {
+	if limits.MaxBodyBytes > 0 {
+		// Read one more byte to detect bodies over the limit.
+		r = io.LimitReader(r, limits.MaxBodyBytes+1)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return Chat{}, fmt.Errorf("failed to read chat: %w", err)
+	}
+	if limits.MaxBodyBytes > 0 && int64(len(body)) > limits.MaxBodyBytes {
+		return Chat{}, &LimitError{Limit: "MaxBodyBytes", Max: limits.MaxBodyBytes}
+	}
+
+	var chat Chat
+	if err := json.Unmarshal(body, &chat); err != nil {
+		return Chat{}, fmt.Errorf("failed to decode chat: %w", err)
+	}
+
+	if limits.MaxMessages > 0 && len(chat.Messages) > limits.MaxMessages {
+		return Chat{}, &LimitError{Limit: "MaxMessages", Max: int64(limits.MaxMessages)}
+	}
+
+	if limits.MaxAttachmentBytes > 0 {
+		for _, message := range chat.Messages {
+			for _, attachment := range message.Attachments {
+				if int64(len(attachment.URL)) > limits.MaxAttachmentBytes {
+					return Chat{}, &LimitError{Limit: "MaxAttachmentBytes", Max: limits.MaxAttachmentBytes}
+				}
+			}
+			for _, part := range message.Parts {
+				if part.Type == PartTypeFile && int64(len(part.Data)) > limits.MaxAttachmentBytes {
+					return Chat{}, &LimitError{Limit: "MaxAttachmentBytes", Max: limits.MaxAttachmentBytes}
+				}
+			}
+		}
+	}
+
+	if err := chat.Validate(); err != nil {
+		return Chat{}, err
+	}
+
+	return chat, nil
+}
@@ -0,0 +1,82 @@
+package aisdk_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeChat(t *testing.T) {
+	t.Parallel()
+
+	body := `{"id":"chat_1","messages":[{"id":"msg_1","role":"user","content":"Hi","parts":[{"type":"text","text":"Hi"}],"experimental_attachments":[{"contentType":"image/png","url":"data:image/png;base64,cG5n"}]}]}`
+
+	chat, err := aisdk.DecodeChat(strings.NewReader(body), aisdk.DefaultLimits)
+	require.NoError(t, err)
+	require.Equal(t, "chat_1", chat.ID)
+	require.Len(t, chat.Messages, 1)
+
+	for _, tc := range []struct {
+		limits aisdk.Limits
+		limit  string
+	}{
+		{aisdk.Limits{MaxBodyBytes: 64}, "MaxBodyBytes"},
+		{aisdk.Limits{}, ""}, // The zero value has no limits.
+		{aisdk.Limits{MaxAttachmentBytes: 16}, "MaxAttachmentBytes"},
+	} {
+		_, err := aisdk.DecodeChat(strings.NewReader(body), tc.limits)
+		if tc.limit == "" {
+			require.NoError(t, err)
+			continue
+		}
+		var limitErr *aisdk.LimitError
+		require.True(t, errors.As(err, &limitErr))
+		require.Equal(t, tc.limit, limitErr.Limit)
+	}
+
+	twoMessages := `{"id":"chat_1","messages":[{"role":"user","content":"Hi"},{"role":"user","content":"Hi"}]}`
+	_, err = aisdk.DecodeChat(strings.NewReader(twoMessages), aisdk.Limits{MaxMessages: 1})
+	var limitErr *aisdk.LimitError
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, "MaxMessages", limitErr.Limit)
+}
+
+func TestChat_Validate(t *testing.T) {
+	t.Parallel()
+
+	chat := func(attachments ...aisdk.Attachment) aisdk.Chat {
+		return aisdk.Chat{Messages: []aisdk.Message{
+			{Role: "user", Content: "Hi"},
+			{Role: "user", Content: "Look", Attachments: attachments},
+		}}
+	}
+	valid := aisdk.Attachment{ContentType: "image/png", URL: "data:image/png;base64,cG5n"}
+
+	require.NoError(t, chat(valid, aisdk.Attachment{URL: "https://example.com/cat.png"}).Validate())
+
+	for _, tc := range []struct {
+		attachment aisdk.Attachment
+		err        string
+	}{
+		{aisdk.Attachment{ContentType: "image/png", URL: "data:image/png;base64"}, "data URL has no data"},
+		{aisdk.Attachment{URL: "data:image/png;base64,cG5n"}, "content type is required for data URLs"},
+		{aisdk.Attachment{ContentType: "image/png", URL: "data:image/png;base64,not base64!"}, "data URL is not valid base64"},
+		{aisdk.Attachment{ContentType: "text/plain", URL: "data:text/plain,hello"}, "data URL must be base64-encoded"},
+		{aisdk.Attachment{URL: "file:///etc/passwd"}, `URL must be a data URL or an http(s) URL, got "file:///etc/passwd"`},
+		{aisdk.Attachment{URL: "cat.png"}, `URL must be a data URL or an http(s) URL, got "cat.png"`},
+	} {
+		err := chat(valid, tc.attachment).Validate()
+		var attachmentErr *aisdk.AttachmentError
+		require.True(t, errors.As(err, &attachmentErr), tc.err)
+		require.Equal(t, 1, attachmentErr.MessageIndex)
+		require.Equal(t, 1, attachmentErr.AttachmentIndex)
+		require.ErrorContains(t, err, "invalid attachment 1 of message 1: "+tc.err)
+	}
+
+	body := `{"id":"chat_1","messages":[{"role":"user","content":"Hi","experimental_attachments":[{"url":"data:image/png;base64,cG5n"}]}]}`
+	_, err := aisdk.DecodeChat(strings.NewReader(body), aisdk.DefaultLimits)
+	require.EqualError(t, err, "invalid attachment 0 of message 0: content type is required for data URLs")
+}
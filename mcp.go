@@ -0,0 +1,69 @@
+package aisdk
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MCPTool is a tool definition in the shape MCP (Model Context Protocol)
+// servers list them in, so the tools of any MCP client library can be
+// converted by marshalling them to JSON and back.
+type MCPTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// InputSchema is the JSON Schema of the tool's arguments, which MCP
+	// requires to be of type object.
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// mcpInputSchema is the part of an MCP input schema that Schema can hold.
+type mcpInputSchema struct {
+	Type       string         `json:"type"`
+	Properties map[string]any `json:"properties"`
+	Required   []string       `json:"required"`
+}
+
+// ToolsFromMCP converts MCP tool definitions to tools for the provider
+// adapters. Keywords at the top level of an input schema other than
+// properties and required, like $defs, are dropped.
+func ToolsFromMCP(mcpTools []MCPTool) ([]Tool, error) {
+	tools := make([]Tool, 0, len(mcpTools))
+	for _, mcpTool := range mcpTools {
+		var schema mcpInputSchema
+		if len(mcpTool.InputSchema) > 0 {
+			if err := json.Unmarshal(mcpTool.InputSchema, &schema); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal input schema of MCP tool %s: %w", mcpTool.Name, err)
+			}
+		}
+		if schema.Type != "" && schema.Type != "object" {
+			return nil, fmt.Errorf("input schema of MCP tool %s has type %q, want object", mcpTool.Name, schema.Type)
+		}
+		tools = append(tools, Tool{
+			Name:        mcpTool.Name,
+			Description: mcpTool.Description,
+			Schema: Schema{
+				Required:   schema.Required,
+				Properties: schema.Properties,
+			},
+		})
+	}
+	return tools, nil
+}
+
+// ToolsToMCP converts tools to MCP tool definitions, e.g. to serve them from
+// an MCP server.
+func ToolsToMCP(tools []Tool) ([]MCPTool, error) {
+	mcpTools := make([]MCPTool, 0, len(tools))
+	for _, tool := range tools {
+		schema, err := tool.JSONSchema()
+		if err != nil {
+			return nil, err
+		}
+		mcpTools = append(mcpTools, MCPTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: schema,
+		})
+	}
+	return mcpTools, nil
+}
@@ -0,0 +1,53 @@
+package aisdk_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolsFromMCP(t *testing.T) {
+	t.Parallel()
+
+	// The result of an MCP server's tools/list.
+	var list struct {
+		Tools []aisdk.MCPTool `json:"tools"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"tools":[{
+		"name": "get_weather",
+		"description": "Get the weather of a city.",
+		"inputSchema": {
+			"type": "object",
+			"properties": {"city": {"type": "string"}},
+			"required": ["city"]
+		}
+	}, {
+		"name": "now",
+		"inputSchema": {"type": "object"}
+	}]}`), &list))
+
+	tools, err := aisdk.ToolsFromMCP(list.Tools)
+	require.NoError(t, err)
+	require.Equal(t, []aisdk.Tool{{
+		Name:        "get_weather",
+		Description: "Get the weather of a city.",
+		Schema: aisdk.Schema{
+			Required:   []string{"city"},
+			Properties: map[string]any{"city": map[string]any{"type": "string"}},
+		},
+	}, {
+		Name: "now",
+	}}, tools)
+
+	// Round-tripping keeps the schema.
+	mcpTools, err := aisdk.ToolsToMCP(tools)
+	require.NoError(t, err)
+	require.Equal(t, "get_weather", mcpTools[0].Name)
+	require.JSONEq(t, `{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`, string(mcpTools[0].InputSchema))
+	require.JSONEq(t, `{"type":"object","properties":{}}`, string(mcpTools[1].InputSchema))
+
+	_, err = aisdk.ToolsFromMCP([]aisdk.MCPTool{{Name: "bad", InputSchema: json.RawMessage(`{"type":"string"}`)}})
+	require.EqualError(t, err, `input schema of MCP tool bad has type "string", want object`)
+}
@@ -1,9 +1,14 @@
 package aisdk
 
 import (
+	"cmp"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/packages/param"
@@ -16,13 +21,7 @@ func ToolsToOpenAI(tools []Tool) []openai.ChatCompletionToolParam {
 	for _, tool := range tools {
 		var schemaParams map[string]any
 		if tool.Schema.Properties != nil {
-			schemaParams = map[string]any{
-				"type":       "object",
-				"properties": tool.Schema.Properties,
-			}
-			if len(tool.Schema.Required) > 0 {
-				schemaParams["required"] = tool.Schema.Required
-			}
+			schemaParams = tool.Schema.jsonSchema()
 		}
 		openaiTools = append(openaiTools, openai.ChatCompletionToolParam{
 			Function: openai.FunctionDefinitionParam{
@@ -35,7 +34,15 @@ func ToolsToOpenAI(tools []Tool) []openai.ChatCompletionToolParam {
 	return openaiTools
 }
 
+// openAIImageTypes are the image MIME types supported by OpenAI.
+var openAIImageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
 // MessagesToOpenAI converts internal message format to OpenAI's API format.
+//
+// Reasoning parts of assistant messages are dropped, since Chat Completions
+// doesn't accept reasoning in the history; o-series models reason afresh on
+// every request. Only the Responses API takes prior reasoning, as encrypted
+// reasoning items.
 func MessagesToOpenAI(messages []Message) ([]openai.ChatCompletionMessageParamUnion, error) {
 	openaiMessages := []openai.ChatCompletionMessageParamUnion{}
 
@@ -43,6 +50,9 @@ func MessagesToOpenAI(messages []Message) ([]openai.ChatCompletionMessageParamUn
 		switch message.Role {
 		case "system":
 			openaiMessages = append(openaiMessages, openai.SystemMessage(message.Content))
+		case "developer":
+			// o-series models take developer messages in place of system messages.
+			openaiMessages = append(openaiMessages, openai.DeveloperMessage(message.Content))
 		case "user":
 			content := []openai.ChatCompletionContentPartUnionParam{}
 			for _, part := range message.Parts {
@@ -54,17 +64,18 @@ func MessagesToOpenAI(messages []Message) ([]openai.ChatCompletionMessageParamUn
 						},
 					})
 				case PartTypeFile:
-					content = append(content, openai.ChatCompletionContentPartUnionParam{
-						OfImageURL: &openai.ChatCompletionContentPartImageParam{
-							ImageURL: openai.ChatCompletionContentPartImageImageURLParam{
-								URL: fmt.Sprintf("data:%s;base64,%s", part.MimeType, base64.StdEncoding.EncodeToString(part.Data)),
-							},
-						},
-					})
+					image, err := openAIImagePart(part)
+					if err != nil {
+						return nil, err
+					}
+					content = append(content, image)
 				}
 			}
 
 			for _, attachment := range message.Attachments {
+				if err := checkImageType("OpenAI", attachment.ContentType, openAIImageTypes); err != nil {
+					return nil, err
+				}
 				content = append(content, openai.ChatCompletionContentPartUnionParam{
 					OfImageURL: &openai.ChatCompletionContentPartImageParam{
 						ImageURL: openai.ChatCompletionContentPartImageImageURLParam{
@@ -87,6 +98,11 @@ func MessagesToOpenAI(messages []Message) ([]openai.ChatCompletionMessageParamUn
 			for _, part := range message.Parts {
 				switch part.Type {
 				case PartTypeText:
+					// Skip empty text left behind by an empty completion, so
+					// that no content-less assistant message is sent.
+					if strings.TrimSpace(part.Text) == "" {
+						continue
+					}
 					content.Content.OfArrayOfContentParts = append(content.Content.OfArrayOfContentParts, openai.ChatCompletionAssistantMessageParamContentArrayOfContentPartUnion{
 						OfText: &openai.ChatCompletionContentPartTextParam{
 							Text: part.Text,
@@ -96,30 +112,35 @@ func MessagesToOpenAI(messages []Message) ([]openai.ChatCompletionMessageParamUn
 					if part.ToolInvocation == nil {
 						return nil, fmt.Errorf("assistant message part has type tool-invocation but nil ToolInvocation field (ID: %s)", message.ID)
 					}
-					argsJSON, err := json.Marshal(part.ToolInvocation.Args)
+					call, result := SplitToolInvocation(part)
+					argsJSON, err := json.Marshal(call.ToolInvocation.Args)
 					if err != nil {
-						return nil, fmt.Errorf("marshalling tool input for call %s: %w", part.ToolInvocation.ToolCallID, err)
+						return nil, fmt.Errorf("marshalling tool input for call %s: %w", call.ToolInvocation.ToolCallID, err)
 					}
 					content.ToolCalls = append(content.ToolCalls, openai.ChatCompletionMessageToolCallParam{
-						ID: part.ToolInvocation.ToolCallID,
+						ID: call.ToolInvocation.ToolCallID,
 						Function: openai.ChatCompletionMessageToolCallFunctionParam{
-							Name:      part.ToolInvocation.ToolName,
+							Name:      call.ToolInvocation.ToolName,
 							Arguments: string(argsJSON),
 						},
 					})
 
-					if part.ToolInvocation.State != ToolInvocationStateResult {
+					if result.ToolInvocation == nil {
 						continue
 					}
 
+					// Text before the call is sent with it, since OpenAI wants
+					// the text and tool_calls of a turn on one message, and the
+					// tool message must follow it directly.
 					openaiMessages = append(openaiMessages, openai.ChatCompletionMessageParamUnion{
 						OfAssistant: content,
 					})
 					content = &openai.ChatCompletionAssistantMessageParam{}
 
 					parts := []openai.ChatCompletionContentPartTextParam{}
+					images := []openai.ChatCompletionContentPartUnionParam{}
 
-					resultParts, err := toolResultToParts(part.ToolInvocation.Result)
+					resultParts, err := toolResultToParts(result.ToolInvocation.Result)
 					if err != nil {
 						return nil, fmt.Errorf("failed to convert tool call result to parts: %w", err)
 					}
@@ -130,22 +151,48 @@ func MessagesToOpenAI(messages []Message) ([]openai.ChatCompletionMessageParamUn
 								Text: resultPart.Text,
 							})
 						case PartTypeFile:
-							// Unfortunately, OpenAI doesn't support file content in tool messages.
-							parts = append(parts, openai.ChatCompletionContentPartTextParam{
-								Text: "File content was provided as a tool result, but is not supported by OpenAI.",
-							})
-							continue
+							image, err := openAIImagePart(resultPart)
+							if err != nil {
+								return nil, err
+							}
+							images = append(images, image)
 						}
 					}
+					if len(parts) == 0 {
+						parts = append(parts, openai.ChatCompletionContentPartTextParam{
+							Text: "The result is attached in the next message.",
+						})
+					}
+					// OpenAI has no error flag on tool messages, so say so in the content.
+					if result.ToolInvocation.IsError {
+						parts[0].Text = "Error: " + parts[0].Text
+					}
 
 					openaiMessages = append(openaiMessages, openai.ChatCompletionMessageParamUnion{
 						OfTool: &openai.ChatCompletionToolMessageParam{
-							ToolCallID: part.ToolInvocation.ToolCallID,
+							ToolCallID: result.ToolInvocation.ToolCallID,
 							Content: openai.ChatCompletionToolMessageParamContentUnion{
 								OfArrayOfContentParts: parts,
 							},
 						},
 					})
+
+					// OpenAI doesn't support images in tool messages, so they
+					// follow the tool message as a user message instead.
+					if len(images) > 0 {
+						images = append([]openai.ChatCompletionContentPartUnionParam{{
+							OfText: &openai.ChatCompletionContentPartTextParam{
+								Text: fmt.Sprintf("Files returned by the %s tool call (ID: %s):", result.ToolInvocation.ToolName, result.ToolInvocation.ToolCallID),
+							},
+						}}, images...)
+						openaiMessages = append(openaiMessages, openai.ChatCompletionMessageParamUnion{
+							OfUser: &openai.ChatCompletionUserMessageParam{
+								Content: openai.ChatCompletionUserMessageParamContentUnion{
+									OfArrayOfContentParts: images,
+								},
+							},
+						})
+					}
 				}
 			}
 
@@ -161,29 +208,175 @@ func MessagesToOpenAI(messages []Message) ([]openai.ChatCompletionMessageParamUn
 		}
 	}
 
+	if len(openaiMessages) == 0 {
+		return nil, errors.New("at least one message required")
+	}
+
 	return openaiMessages, nil
 }
 
+// openAIUsage converts OpenAI's usage. Prediction tokens are only set if the
+// request had a predicted output.
+func openAIUsage(u openai.CompletionUsage) Usage {
+	usage := Usage{
+		PromptTokens:     &u.PromptTokens,
+		CompletionTokens: &u.CompletionTokens,
+	}
+	if details := u.CompletionTokensDetails; details.AcceptedPredictionTokens > 0 || details.RejectedPredictionTokens > 0 {
+		usage.AcceptedPredictionTokens = &details.AcceptedPredictionTokens
+		usage.RejectedPredictionTokens = &details.RejectedPredictionTokens
+	}
+	return usage
+}
+
+// hasOpenAIUsage returns true if usage was reported. Not every
+// OpenAI-compatible provider sends total_tokens, so any count will do.
+func hasOpenAIUsage(u openai.CompletionUsage) bool {
+	return u.TotalTokens > 0 || u.PromptTokens > 0 || u.CompletionTokens > 0
+}
+
+// openAIImagePart converts a file part to an image content part, inlining
+// the data as a data URL if the part has no URL.
+func openAIImagePart(part Part) (openai.ChatCompletionContentPartUnionParam, error) {
+	if err := checkImageType("OpenAI", part.MimeType, openAIImageTypes); err != nil {
+		return openai.ChatCompletionContentPartUnionParam{}, err
+	}
+	url := part.URL
+	if url == "" {
+		url = fmt.Sprintf("data:%s;base64,%s", part.MimeType, base64.StdEncoding.EncodeToString(part.Data))
+	}
+	return openai.ChatCompletionContentPartUnionParam{
+		OfImageURL: &openai.ChatCompletionContentPartImageParam{
+			ImageURL: openai.ChatCompletionContentPartImageImageURLParam{
+				URL: url,
+			},
+		},
+	}, nil
+}
+
 // OpenAIToDataStream pipes an OpenAI stream to a DataStream.
+// Errors of the stream are yielded as *ProviderError, wrapping a
+// *RateLimitError if the request was rate limited.
+//
+// OpenAI-compatible providers that extend the chunk with a top-level
+// `citations` field, like Perplexity, have every cited URL emitted once as a
+// SourceStreamPart. The source ID is the 1-based citation index, matching the
+// inline `[1]` references in the answer text.
+//
+// Annotations on delta.annotations are emitted as they arrive: a url_citation
+// of web search as a SourceStreamPart with the URL as ID, once per URL, and a
+// file_citation of file search as a MessageAnnotationStreamPart of type
+// "file-citation" with the fileId, filename, text, startIndex and endIndex of
+// the citation.
+//
+// Only a single choice is supported. A stream of a request with n > 1 yields
+// an error at the first chunk of another choice; use a non-streaming request
+// and OpenAIResponseToMessages for multiple choices.
+//
+// OpenAI reports usage only once the completion is done, and only with
+// stream_options.include_usage, so it arrives with the finish parts.
+//
+// OpenAI-compatible gateways are streamed with the OpenAI client pointed at
+// their base URL. The wrappers for them, like TogetherToDataStream, behave
+// like OpenAIToDataStream, except that errors name the gateway as the
+// provider.
 func OpenAIToDataStream(stream *ssestream.Stream[openai.ChatCompletionChunk]) DataStream {
+	return openAIToDataStream("openai", stream)
+}
+
+// openAIToDataStream pipes an OpenAI stream to a DataStream. provider names the
+// OpenAI-compatible provider in errors.
+func openAIToDataStream(provider string, stream *ssestream.Stream[openai.ChatCompletionChunk]) DataStream {
 	return func(yield func(DataStreamPart, error) bool) {
-		var lastChunk *openai.ChatCompletionChunk
-		var currentToolCallID string
+		var lastChoice *openai.ChatCompletionChunkChoice
+		// Tool call deltas are keyed by index, and only the first delta of
+		// a call has its ID. The calls are kept in the order they started.
+		var toolCalls []*pendingToolCall
+		toolCallsByIndex := make(map[int64]*pendingToolCall)
+		var stepFinished bool
+		var usage Usage
+		var refusal string
+		var audio []byte
+		citations := make(map[string]struct{})
 
-		if stream.Err() != nil {
-			if !yield(ErrorStreamPart{Content: stream.Err().Error()}, nil) {
-				return
+		// The start of a tool call is held back until its name is known, so
+		// that no call to an empty tool name is emitted. The args received in
+		// the meantime are emitted with the start.
+		startToolCall := func(call *pendingToolCall) bool {
+			if call.started {
+				return true
+			}
+			call.started = true
+			if !yield(ToolCallStartStreamPart{
+				ToolCallID: call.ID,
+				ToolName:   call.Name,
+				Index:      call.Index,
+			}, nil) {
+				return false
+			}
+			if call.Args == "" {
+				return true
 			}
+			return yield(ToolCallDeltaStreamPart{
+				ToolCallID:    call.ID,
+				ArgsTextDelta: call.Args,
+				Index:         call.Index,
+			}, nil)
+		}
+		// startToolCalls starts the calls whose name never arrived.
+		startToolCalls := func() bool {
+			for _, call := range toolCalls {
+				if !startToolCall(call) {
+					return false
+				}
+			}
+			return true
 		}
 
 		for stream.Next() {
 			chunk := stream.Current()
-			lastChunk = &chunk
+
+			// With `stream_options.include_usage`, usage arrives in a final
+			// chunk with no choices, after the finish reason. Together and
+			// Fireworks send it with the finish reason instead.
+			if hasOpenAIUsage(chunk.Usage) {
+				usage = openAIUsage(chunk.Usage)
+			}
+
+			// Only providers that extend the chunk send citations, so
+			// standard OpenAI chunks are never parsed twice.
+			if field, ok := chunk.JSON.ExtraFields["citations"]; ok {
+				var urls []string
+				if err := json.Unmarshal([]byte(field.Raw()), &urls); err == nil {
+					for i, url := range urls {
+						if _, ok := citations[url]; ok {
+							continue
+						}
+						citations[url] = struct{}{}
+						if !yield(SourceStreamPart{
+							SourceType: "url",
+							ID:         strconv.Itoa(i + 1),
+							URL:        url,
+						}, nil) {
+							return
+						}
+					}
+				}
+			}
 
 			if len(chunk.Choices) == 0 {
-				break
+				continue
+			}
+			// Choices of n > 1 are interleaved in the stream, and their parts
+			// would be mixed up in a single message.
+			for _, choice := range chunk.Choices {
+				if choice.Index != 0 {
+					yield(nil, &ProviderError{Provider: provider, Err: fmt.Errorf("received choice %d, but only a single choice is supported, request n = 1", choice.Index)})
+					return
+				}
 			}
 			choice := chunk.Choices[0]
+			lastChoice = &choice
 
 			if choice.Delta.Content != "" {
 				// Yield a Part object instead of TextStreamPart
@@ -192,67 +385,308 @@ func OpenAIToDataStream(stream *ssestream.Stream[openai.ChatCompletionChunk]) Da
 				}
 			}
 
+			// Refusals are streamed like content, but are yielded as a
+			// single part since clients render them as an error.
+			refusal += choice.Delta.Refusal
+
+			// Audio output is a non-standard field of the delta. Its
+			// transcript is streamed as text, and the audio is yielded as
+			// a single file once complete.
+			if field, ok := choice.Delta.JSON.ExtraFields["audio"]; ok {
+				var delta struct {
+					Data       string `json:"data"`
+					Transcript string `json:"transcript"`
+				}
+				if err := json.Unmarshal([]byte(field.Raw()), &delta); err == nil {
+					if delta.Data != "" {
+						data, err := base64.StdEncoding.DecodeString(delta.Data)
+						if err != nil {
+							yield(nil, &ProviderError{Provider: provider, Err: fmt.Errorf("failed to decode audio delta: %w", err)})
+							return
+						}
+						audio = append(audio, data...)
+					}
+					if delta.Transcript != "" {
+						if !yield(TextStreamPart{Content: delta.Transcript}, nil) {
+							return
+						}
+					}
+				}
+			}
+
+			// Annotations of the text, like the citations of web and file
+			// search, are a non-standard field of the delta.
+			if field, ok := choice.Delta.JSON.ExtraFields["annotations"]; ok {
+				var annotations []openAIAnnotation
+				if err := json.Unmarshal([]byte(field.Raw()), &annotations); err == nil {
+					for _, annotation := range annotations {
+						part := annotation.streamPart()
+						if source, ok := part.(SourceStreamPart); ok {
+							if _, ok := citations[source.URL]; ok {
+								continue
+							}
+							citations[source.URL] = struct{}{}
+						}
+						if part != nil && !yield(part, nil) {
+							return
+						}
+					}
+				}
+			}
+
 			for _, toolCallDelta := range choice.Delta.ToolCalls {
 				// The tool call ID is only present in the first delta.
 				if toolCallDelta.ID != "" {
-					currentToolCallID = toolCallDelta.ID // Update current ID when starting new tool call
-					if !yield(ToolCallStartStreamPart{
-						ToolCallID: currentToolCallID,
-						ToolName:   toolCallDelta.Function.Name,
-					}, nil) {
+					// Start the previous tool calls if their name never arrived.
+					if !startToolCalls() {
+						return
+					}
+					// Some OpenAI-compatible providers send every call at
+					// index 0, so the index of the part is counted instead.
+					call := &pendingToolCall{ID: toolCallDelta.ID, Index: len(toolCalls)}
+					toolCalls = append(toolCalls, call)
+					toolCallsByIndex[toolCallDelta.Index] = call
+				}
+				call, ok := toolCallsByIndex[toolCallDelta.Index]
+				if !ok {
+					if toolCallDelta.Function.Arguments != "" {
+						yield(nil, &ProviderError{Provider: provider, Err: fmt.Errorf("received tool call delta with empty ID and no tool call at index %d", toolCallDelta.Index)})
 						return
 					}
+					continue
+				}
+				// The name usually arrives with the ID, but may follow in a later delta.
+				if call.Name == "" {
+					call.Name = toolCallDelta.Function.Name
 				}
 
 				// Only emit delta parts if we have arguments
 				if toolCallDelta.Function.Arguments != "" {
-					if currentToolCallID == "" {
-						if !yield(nil, fmt.Errorf("received tool call delta with empty ID and no current tool call")) {
-							return
-						}
-						continue
-					}
-					if !yield(ToolCallDeltaStreamPart{
-						ToolCallID:    currentToolCallID,
+					if !call.started {
+						call.Args += toolCallDelta.Function.Arguments
+					} else if !yield(ToolCallDeltaStreamPart{
+						ToolCallID:    call.ID,
 						ArgsTextDelta: toolCallDelta.Function.Arguments,
+						Index:         call.Index,
 					}, nil) {
 						return
 					}
 				}
-			}
 
-			if choice.FinishReason != "" {
-				var finishReason FinishReason
-				switch choice.FinishReason {
-				case "tool_calls":
-					finishReason = FinishReasonToolCalls
-				default:
-					finishReason = FinishReasonStop
-				}
-				if !yield(FinishStepStreamPart{
-					IsContinued:  false,
-					FinishReason: finishReason,
-				}, nil) {
+				if call.Name != "" && !startToolCall(call) {
 					return
 				}
 			}
+
+			if choice.FinishReason != "" {
+				// The step is finished once the stream ends, so that
+				// usage sent after the finish reason is included.
+				stepFinished = true
+			}
 		}
 
-		var finishReason FinishReason
+		// Handle any errors from the stream, including failing to connect.
+		if err := stream.Err(); err != nil {
+			var apiErr *openai.Error
+			if errors.As(err, &apiErr) && apiErr.Response != nil {
+				err = rateLimitError(apiErr.StatusCode, apiErr.Response.Header, err)
+			}
+			yield(nil, &ProviderError{Provider: provider, Err: err})
+			return
+		}
 
-		if lastChunk != nil && len(lastChunk.Choices) > 0 {
-			choice := lastChunk.Choices[0]
+		if !startToolCalls() {
+			return
+		}
 
-			switch choice.FinishReason {
+		if len(audio) > 0 {
+			if !yield(FileStreamPart{Data: audio, MimeType: openAIStreamAudioType}, nil) {
+				return
+			}
+		}
+
+		var finishReason FinishReason
+
+		if lastChoice != nil {
+			// Other finish reasons, like the "eos" of some Together
+			// models, end the response like "stop".
+			switch lastChoice.FinishReason {
 			case "tool_calls":
 				finishReason = FinishReasonToolCalls
+			case "content_filter":
+				finishReason = FinishReasonContentFilter
+			case "length":
+				finishReason = FinishReasonLength
 			default:
 				finishReason = FinishReasonStop
 			}
 		}
 
+		if refusal != "" {
+			finishReason = FinishReasonContentFilter
+			if !yield(RefusalStreamPart{Content: refusal}, nil) {
+				return
+			}
+		}
+
+		if stepFinished {
+			if !yield(FinishStepStreamPart{
+				// A response cut off at max_tokens can be continued by
+				// another request, whose text continues this message.
+				IsContinued:  finishReason == FinishReasonLength,
+				FinishReason: finishReason,
+				Usage:        usage,
+			}, nil) {
+				return
+			}
+		}
+
 		yield(FinishMessageStreamPart{
 			FinishReason: finishReason,
+			Usage:        usage,
 		}, nil)
 	}
 }
+
+// openAIAnnotation is an annotation of the text in a streamed delta.
+type openAIAnnotation struct {
+	Type        string `json:"type"`
+	URLCitation struct {
+		URL   string `json:"url"`
+		Title string `json:"title"`
+	} `json:"url_citation"`
+	FileCitation struct {
+		FileID   string `json:"file_id"`
+		Filename string `json:"filename"`
+	} `json:"file_citation"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+	Text       string `json:"text"`
+}
+
+// streamPart returns a url_citation as a SourceStreamPart, and a
+// file_citation as a MessageAnnotationStreamPart that keeps the cited file
+// and the range of the text it supports. Other annotations return nil.
+func (a openAIAnnotation) streamPart() DataStreamPart {
+	switch a.Type {
+	case "url_citation":
+		return SourceStreamPart{
+			SourceType: "url",
+			ID:         a.URLCitation.URL,
+			URL:        a.URLCitation.URL,
+			Title:      a.URLCitation.Title,
+		}
+	case "file_citation":
+		return MessageAnnotationStreamPart{Content: []any{map[string]any{
+			"type":       "file-citation",
+			"fileId":     a.FileCitation.FileID,
+			"filename":   a.FileCitation.Filename,
+			"text":       a.Text,
+			"startIndex": a.StartIndex,
+			"endIndex":   a.EndIndex,
+		}}}
+	}
+	return nil
+}
+
+// openAIStreamAudioType is the MIME type of streamed audio output, which OpenAI
+// only supports as 16-bit PCM at 24kHz.
+const openAIStreamAudioType = "audio/pcm"
+
+// pendingToolCall is a streamed OpenAI tool call. Args holds the args received
+// before the start of the call was emitted.
+type pendingToolCall struct {
+	ID      string
+	Name    string
+	Args    string
+	Index   int
+	started bool
+}
+
+// OpenAIResponseToMessage converts a non-streaming chat completion to a Message,
+// with the same representation as an accumulated OpenAIToDataStream. Tool calls
+// are complete, like those passed through WithToolCalling. Only the first
+// choice is converted; use OpenAIResponseToMessages for requests with n > 1.
+func OpenAIResponseToMessage(resp openai.ChatCompletion) (Message, error) {
+	if len(resp.Choices) == 0 {
+		return Message{}, fmt.Errorf("chat completion %s has no choices", resp.ID)
+	}
+	return openAIChoiceToMessage(resp, resp.Choices[0])
+}
+
+// OpenAIResponseToMessages converts every choice of a non-streaming chat
+// completion to a Message, ordered by choice index, e.g. for best-of-n
+// sampling. OpenAI reports usage for the whole completion, so every message
+// has the same usage.
+func OpenAIResponseToMessages(resp openai.ChatCompletion) ([]Message, error) {
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("chat completion %s has no choices", resp.ID)
+	}
+	choices := slices.Clone(resp.Choices)
+	slices.SortFunc(choices, func(a, b openai.ChatCompletionChoice) int {
+		return cmp.Compare(a.Index, b.Index)
+	})
+	messages := make([]Message, 0, len(choices))
+	for _, choice := range choices {
+		message, err := openAIChoiceToMessage(resp, choice)
+		if err != nil {
+			return nil, fmt.Errorf("choice %d: %w", choice.Index, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func openAIChoiceToMessage(resp openai.ChatCompletion, choice openai.ChatCompletionChoice) (Message, error) {
+	var parts []DataStreamPart
+	if choice.Message.Content != "" {
+		parts = append(parts, TextStreamPart{Content: choice.Message.Content})
+	}
+	for i, toolCall := range choice.Message.ToolCalls {
+		args := map[string]any{}
+		if toolCall.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+				return Message{}, fmt.Errorf("failed to parse arguments for tool call %s: %w", toolCall.ID, err)
+			}
+		}
+		parts = append(parts, ToolCallStartStreamPart{
+			ToolCallID: toolCall.ID,
+			ToolName:   toolCall.Function.Name,
+			Index:      i,
+		}, ToolCallStreamPart{
+			ToolCallID: toolCall.ID,
+			ToolName:   toolCall.Function.Name,
+			Args:       args,
+		})
+	}
+
+	var finishReason FinishReason
+	switch choice.FinishReason {
+	case "tool_calls":
+		finishReason = FinishReasonToolCalls
+	case "content_filter":
+		finishReason = FinishReasonContentFilter
+	case "length":
+		finishReason = FinishReasonLength
+	default:
+		finishReason = FinishReasonStop
+	}
+	if choice.Message.Refusal != "" {
+		finishReason = FinishReasonContentFilter
+		parts = append(parts, RefusalStreamPart{Content: choice.Message.Refusal})
+	}
+
+	var usage Usage
+	if hasOpenAIUsage(resp.Usage) {
+		usage = openAIUsage(resp.Usage)
+	}
+	parts = append(parts, FinishStepStreamPart{
+		FinishReason: finishReason,
+		Usage:        usage,
+	}, FinishMessageStreamPart{
+		FinishReason: finishReason,
+		Usage:        usage,
+	})
+
+	return partsToMessage(parts)
+}
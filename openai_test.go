@@ -2,11 +2,16 @@ package aisdk_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/morecommits/aisdk-go"
 	"github.com/openai/openai-go"
@@ -90,6 +95,7 @@ data: [DONE]`
 					Type: aisdk.PartTypeToolInvocation,
 					ToolInvocation: &aisdk.ToolInvocation{
 						State:      aisdk.ToolInvocationStateResult,
+						Step:       intPtr(0),
 						ToolCallID: "call_acK2pxwOef03RhfTFTbuPTkR",
 						ToolName:   "test",
 						Args:       map[string]any{"message": "This is a test run as requested."},
@@ -176,3 +182,723 @@ func TestMessagesToOpenAI_Live(t *testing.T) {
 	})
 	require.NoError(t, streamErr)
 }
+
+func TestOpenAIToDataStream_Citations(t *testing.T) {
+	t.Parallel()
+
+	// Perplexity repeats the top-level citations on every chunk.
+	mockResponse := `data: {"id":"5d6f3b1e","model":"sonar","object":"chat.completion.chunk","created":1744123083,"citations":["https://go.dev/doc","https://pkg.go.dev/iter"],"choices":[{"index":0,"delta":{"role":"assistant","content":"Go 1.23 added range-over-func iterators [1]"},"finish_reason":null}]}
+
+data: {"id":"5d6f3b1e","model":"sonar","object":"chat.completion.chunk","created":1744123083,"citations":["https://go.dev/doc","https://pkg.go.dev/iter"],"choices":[{"index":0,"delta":{"content":" via the iter package [2]."},"finish_reason":"stop"}]}
+
+data: [DONE]`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(mockResponse)),
+	})
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+	var acc aisdk.DataStreamAccumulator
+	stream := aisdk.OpenAIToDataStream(typedStream).WithAccumulator(&acc)
+	for _, err := range stream {
+		require.NoError(t, err)
+	}
+
+	messages := acc.Messages()
+	require.Len(t, messages, 1)
+	require.Equal(t, "Go 1.23 added range-over-func iterators [1] via the iter package [2].", messages[0].Content)
+
+	var sources []*aisdk.SourceInfo
+	for _, part := range messages[0].Parts {
+		if part.Type == aisdk.PartTypeSource {
+			sources = append(sources, part.Source)
+		}
+	}
+	require.Len(t, sources, 2)
+	require.Equal(t, "https://go.dev/doc", sources[0].URI)
+	require.Equal(t, "1", sources[0].Metadata["id"])
+	require.Equal(t, "https://pkg.go.dev/iter", sources[1].URI)
+	require.Equal(t, "2", sources[1].Metadata["id"])
+}
+
+func TestOpenAIToDataStream_CompatibleUsage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		toStream func(*ssestream.Stream[openai.ChatCompletionChunk]) aisdk.DataStream
+		response string
+	}{{
+		// Together sends usage with the finish reason, which is "eos" for
+		// some models.
+		name:     "together",
+		toStream: aisdk.TogetherToDataStream,
+		response: `data: {"id":"8f4e2a","object":"chat.completion.chunk","created":1744123083,"model":"meta-llama/Llama-3.3-70B-Instruct-Turbo","choices":[{"index":0,"text":"Hello!","logprobs":null,"finish_reason":null,"seed":null,"delta":{"token_id":9906,"role":"assistant","content":"Hello!","tool_calls":null}}],"usage":null}
+
+data: {"id":"8f4e2a","object":"chat.completion.chunk","created":1744123083,"model":"meta-llama/Llama-3.3-70B-Instruct-Turbo","choices":[{"index":0,"text":"","logprobs":null,"finish_reason":"eos","seed":7,"delta":{"token_id":128009,"role":"assistant","content":"","tool_calls":null}}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}
+
+data: [DONE]`,
+	}, {
+		// Fireworks sends usage with the finish reason.
+		name:     "fireworks",
+		toStream: aisdk.FireworksToDataStream,
+		response: `data: {"id":"c1d2e3","object":"chat.completion.chunk","created":1744123083,"model":"accounts/fireworks/models/llama-v3p1-8b-instruct","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"},"finish_reason":null}],"usage":null}
+
+data: {"id":"c1d2e3","object":"chat.completion.chunk","created":1744123083,"model":"accounts/fireworks/models/llama-v3p1-8b-instruct","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"total_tokens":15,"completion_tokens":3}}
+
+data: [DONE]`,
+	}, {
+		// Not every gateway sends total_tokens.
+		name:     "without total",
+		toStream: aisdk.OpenAIToDataStream,
+		response: `data: {"id":"d4e5f6","object":"chat.completion.chunk","created":1744123083,"model":"llama","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}]}
+
+data: {"id":"d4e5f6","object":"chat.completion.chunk","created":1744123083,"model":"llama","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3}}
+
+data: [DONE]`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoder := ssestream.NewDecoder(&http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(tt.response)),
+			})
+			typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+			var acc aisdk.DataStreamAccumulator
+			require.NoError(t, tt.toStream(typedStream).WithAccumulator(&acc).Drain())
+			require.Equal(t, "Hello!", acc.Messages()[0].Content)
+			require.Equal(t, aisdk.FinishReasonStop, acc.FinishReason())
+			require.Equal(t, int64(12), *acc.Usage().PromptTokens)
+			require.Equal(t, int64(3), *acc.Usage().CompletionTokens)
+		})
+	}
+}
+
+func TestOpenAIToDataStream_Annotations(t *testing.T) {
+	t.Parallel()
+
+	mockResponse := `data: {"id":"chatcmpl-ann","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Refunds take 5 days."},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-ann","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"annotations":[{"type":"file_citation","text":"Refunds take 5 days.","start_index":0,"end_index":20,"file_citation":{"file_id":"file-abc","filename":"policy.pdf"}},{"type":"url_citation","url_citation":{"start_index":0,"end_index":20,"url":"https://example.com/refunds","title":"Refunds"}}]},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-ann","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"annotations":[{"type":"url_citation","url_citation":{"start_index":0,"end_index":20,"url":"https://example.com/refunds","title":"Refunds"}}]},"finish_reason":"stop"}]}
+
+data: [DONE]`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(mockResponse)),
+	})
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+	var acc aisdk.DataStreamAccumulator
+	require.NoError(t, aisdk.OpenAIToDataStream(typedStream).WithAccumulator(&acc).Drain())
+
+	message := acc.Messages()[0]
+	require.Equal(t, []any{map[string]any{
+		"type":       "file-citation",
+		"fileId":     "file-abc",
+		"filename":   "policy.pdf",
+		"text":       "Refunds take 5 days.",
+		"startIndex": 0,
+		"endIndex":   20,
+	}}, message.Annotations)
+
+	var sources []*aisdk.SourceInfo
+	for _, part := range message.Parts {
+		if part.Type == aisdk.PartTypeSource {
+			sources = append(sources, part.Source)
+		}
+	}
+	require.Len(t, sources, 1)
+	require.Equal(t, "https://example.com/refunds", sources[0].URI)
+	require.Equal(t, "Refunds", sources[0].Metadata["title"])
+}
+
+func TestOpenAIToDataStream_EmptyCompletion(t *testing.T) {
+	t.Parallel()
+
+	mockResponse := `data: {"id":"chatcmpl-empty","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-empty","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: [DONE]`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(mockResponse)),
+	})
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range aisdk.OpenAIToDataStream(typedStream).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.Len(t, acc.Messages(), 1)
+
+	messages := []aisdk.Message{
+		{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Hello"}}},
+		acc.Messages()[0],
+		{Role: "assistant", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "  \n"}}},
+	}
+	openaiMsgs, err := aisdk.MessagesToOpenAI(messages)
+	require.NoError(t, err)
+
+	// Neither empty assistant message must be sent.
+	require.Len(t, openaiMsgs, 1)
+	require.NotNil(t, openaiMsgs[0].OfUser)
+}
+
+func TestOpenAIToDataStream_Refusal(t *testing.T) {
+	t.Parallel()
+
+	// A structured-output request refused by the model.
+	mockResponse := `data: {"id":"chatcmpl-refusal","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":null,"refusal":""},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-refusal","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"refusal":"I'm sorry, "},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-refusal","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"refusal":"I can't assist with that."},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-refusal","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: [DONE]`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(mockResponse)),
+	})
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+	var out strings.Builder
+	var acc aisdk.DataStreamAccumulator
+	err := aisdk.OpenAIToDataStream(typedStream).WithAccumulator(&acc).Pipe(&out)
+	require.NoError(t, err)
+
+	require.Equal(t, `3:"I'm sorry, I can't assist with that."
+e:{"finishReason":"content-filter","usage":{"promptTokens":null,"completionTokens":null},"isContinued":false}
+d:{"finishReason":"content-filter","usage":{"promptTokens":null,"completionTokens":null}}
+`, out.String())
+	require.Equal(t, "I'm sorry, I can't assist with that.", acc.Refusal())
+	require.Equal(t, aisdk.FinishReasonContentFilter, acc.FinishReason())
+}
+
+func TestOpenAIToDataStream_LateToolName(t *testing.T) {
+	t.Parallel()
+
+	// The tool name arrives in the delta after the ID.
+	mockResponse := `data: {"id":"chatcmpl-late","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":null,"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"","arguments":""}}]},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-late","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"name":"print","arguments":"{\"message\":"}}]},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-late","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"hi\"}"}}]},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-late","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: [DONE]`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(mockResponse)),
+	})
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+	var parts []aisdk.DataStreamPart
+	var calls []aisdk.ToolCall
+	stream := aisdk.OpenAIToDataStream(typedStream).WithToolCalling(func(toolCall aisdk.ToolCall) any {
+		calls = append(calls, toolCall)
+		return "printed"
+	})
+	for part, err := range stream {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+
+	require.Equal(t, aisdk.ToolCallStartStreamPart{ToolCallID: "call_1", ToolName: "print"}, parts[0])
+	require.Equal(t, aisdk.ToolCallDeltaStreamPart{ToolCallID: "call_1", ArgsTextDelta: `{"message":`}, parts[1])
+	require.Equal(t, []aisdk.ToolCall{{ID: "call_1", Name: "print", Args: map[string]any{"message": "hi"}}}, calls)
+}
+
+func TestOpenAIToDataStream_ParallelToolCalls(t *testing.T) {
+	t.Parallel()
+
+	// Both calls start before their args arrive, keyed only by index.
+	mockResponse := `data: {"id":"chatcmpl-par","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"time","arguments":""}},{"index":1,"id":"call_2","type":"function","function":{"name":"weather","arguments":""}}]},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-par","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"{\"city\":\"Paris\"}"}}]},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-par","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"zone\":\"UTC\"}"}}]},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-par","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: [DONE]`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(mockResponse)),
+	})
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+	var parts []aisdk.DataStreamPart
+	calls := make(map[string]aisdk.ToolCall)
+	stream := aisdk.OpenAIToDataStream(typedStream).WithToolCalling(func(toolCall aisdk.ToolCall) any {
+		calls[toolCall.ID] = toolCall
+		return "done"
+	})
+	for part, err := range stream {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+
+	require.Equal(t, aisdk.ToolCallStartStreamPart{ToolCallID: "call_1", ToolName: "time"}, parts[0])
+	require.Equal(t, aisdk.ToolCallStartStreamPart{ToolCallID: "call_2", ToolName: "weather", Index: 1}, parts[1])
+	require.Equal(t, aisdk.ToolCallDeltaStreamPart{ToolCallID: "call_2", ArgsTextDelta: `{"city":"Paris"}`, Index: 1}, parts[2])
+	require.Equal(t, map[string]any{"city": "Paris"}, calls["call_2"].Args)
+	require.Equal(t, map[string]any{"zone": "UTC"}, calls["call_1"].Args)
+}
+
+func TestOpenAIToDataStream_Audio(t *testing.T) {
+	t.Parallel()
+
+	// Audio data is streamed in base64 chunks alongside its transcript.
+	mockResponse := `data: {"id":"chatcmpl-audio","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o-audio-preview","choices":[{"index":0,"delta":{"role":"assistant","content":null,"audio":{"id":"audio_1","transcript":"Hello"}},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-audio","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o-audio-preview","choices":[{"index":0,"delta":{"audio":{"data":"AAEC"}},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-audio","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o-audio-preview","choices":[{"index":0,"delta":{"audio":{"transcript":" there!","data":"AwQF"}},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-audio","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o-audio-preview","choices":[{"index":0,"delta":{"audio":{"expires_at":1744126683}},"finish_reason":"stop"}]}
+
+data: [DONE]`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(mockResponse)),
+	})
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range aisdk.OpenAIToDataStream(typedStream).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.Len(t, acc.Messages(), 1)
+
+	message := acc.Messages()[0]
+	require.Equal(t, "Hello there!", message.TextContent())
+
+	var files []aisdk.Part
+	for _, part := range message.Parts {
+		if part.Type == aisdk.PartTypeFile {
+			files = append(files, part)
+		}
+	}
+	require.Len(t, files, 1)
+	require.Equal(t, "audio/pcm", files[0].MimeType)
+	require.Equal(t, []byte{0, 1, 2, 3, 4, 5}, files[0].Data)
+}
+
+func TestOpenAIToDataStream_InvalidAudio(t *testing.T) {
+	t.Parallel()
+
+	mockResponse := `data: {"id":"chatcmpl-audio","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o-audio-preview","choices":[{"index":0,"delta":{"role":"assistant","content":null,"audio":{"id":"audio_1","data":"not base64!"}},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-audio","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o-audio-preview","choices":[{"index":0,"delta":{"audio":{"transcript":"Hello"}},"finish_reason":"stop"}]}
+
+data: [DONE]`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(mockResponse)),
+	})
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+	// The stream ends at the error.
+	var parts []aisdk.DataStreamPart
+	var errs []error
+	for part, err := range aisdk.OpenAIToDataStream(typedStream) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		parts = append(parts, part)
+	}
+	require.Empty(t, parts)
+	require.Len(t, errs, 1)
+	require.ErrorContains(t, errs[0], "failed to decode audio delta")
+}
+
+func TestMessagesToOpenAI_FileURL(t *testing.T) {
+	t.Parallel()
+
+	messages, err := aisdk.MessagesToOpenAI([]aisdk.Message{{
+		Role: "user",
+		Parts: []aisdk.Part{
+			{Type: aisdk.PartTypeFile, MimeType: "image/png", URL: "https://example.com/cat.png"},
+			{Type: aisdk.PartTypeFile, MimeType: "image/png", Data: []byte("png")},
+		},
+	}})
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	content := messages[0].OfUser.Content.OfArrayOfContentParts
+	require.Len(t, content, 2)
+	require.Equal(t, "https://example.com/cat.png", content[0].OfImageURL.ImageURL.URL)
+	require.Equal(t, "data:image/png;base64,cG5n", content[1].OfImageURL.ImageURL.URL)
+}
+
+func TestMessagesToOpenAI_MultiPartToolResult(t *testing.T) {
+	t.Parallel()
+
+	messages, err := aisdk.MessagesToOpenAI([]aisdk.Message{{
+		Role: "assistant",
+		Parts: []aisdk.Part{{
+			Type: aisdk.PartTypeToolInvocation,
+			ToolInvocation: &aisdk.ToolInvocation{
+				State:      aisdk.ToolInvocationStateResult,
+				ToolCallID: "call_1",
+				ToolName:   "screenshot",
+				Args:       map[string]any{},
+				Result: aisdk.ToolResult(
+					aisdk.Part{Type: aisdk.PartTypeText, Text: "The login page."},
+					aisdk.Part{Type: aisdk.PartTypeFile, MimeType: "image/png", Data: []byte("png")},
+				),
+			},
+		}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+
+	require.Len(t, messages[0].OfAssistant.ToolCalls, 1)
+	tool := messages[1].OfTool
+	require.NotNil(t, tool)
+	require.Equal(t, "call_1", tool.ToolCallID)
+	require.Len(t, tool.Content.OfArrayOfContentParts, 1)
+	require.Equal(t, "The login page.", tool.Content.OfArrayOfContentParts[0].Text)
+
+	// Images can't be sent in tool messages, so they follow as a user message.
+	user := messages[2].OfUser
+	require.NotNil(t, user)
+	content := user.Content.OfArrayOfContentParts
+	require.Len(t, content, 2)
+	require.Contains(t, content[0].OfText.Text, "call_1")
+	require.Equal(t, "data:image/png;base64,cG5n", content[1].OfImageURL.ImageURL.URL)
+}
+
+func TestMessagesToOpenAI_ErrorToolResult(t *testing.T) {
+	t.Parallel()
+
+	messages, err := aisdk.MessagesToOpenAI([]aisdk.Message{{
+		Role: "assistant",
+		Parts: []aisdk.Part{{
+			Type: aisdk.PartTypeToolInvocation,
+			ToolInvocation: &aisdk.ToolInvocation{
+				State:      aisdk.ToolInvocationStateResult,
+				ToolCallID: "call_2",
+				ToolName:   "screenshot",
+				Args:       map[string]any{},
+				Result:     "browser crashed",
+				IsError:    true,
+			},
+		}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, `Error: "browser crashed"`, messages[1].OfTool.Content.OfArrayOfContentParts[0].Text)
+}
+
+func TestMessagesToOpenAI_TextBeforeToolCall(t *testing.T) {
+	t.Parallel()
+
+	messages, err := aisdk.MessagesToOpenAI([]aisdk.Message{{
+		Role: "assistant",
+		Parts: []aisdk.Part{{
+			Type: aisdk.PartTypeText,
+			Text: "Let me check the weather.",
+		}, {
+			Type: aisdk.PartTypeToolInvocation,
+			ToolInvocation: &aisdk.ToolInvocation{
+				State:      aisdk.ToolInvocationStateResult,
+				ToolCallID: "call_1",
+				ToolName:   "weather",
+				Args:       map[string]any{"city": "Paris"},
+				Result:     "sunny",
+			},
+		}, {
+			Type: aisdk.PartTypeText,
+			Text: "It's sunny in Paris.",
+		}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+
+	// The text and the tool call share the assistant message.
+	assistant := messages[0].OfAssistant
+	require.NotNil(t, assistant)
+	require.Len(t, assistant.Content.OfArrayOfContentParts, 1)
+	require.Equal(t, "Let me check the weather.", assistant.Content.OfArrayOfContentParts[0].OfText.Text)
+	require.Len(t, assistant.ToolCalls, 1)
+	require.Equal(t, "call_1", assistant.ToolCalls[0].ID)
+
+	require.Equal(t, "call_1", messages[1].OfTool.ToolCallID)
+
+	after := messages[2].OfAssistant
+	require.NotNil(t, after)
+	require.Empty(t, after.ToolCalls)
+	require.Equal(t, "It's sunny in Paris.", after.Content.OfArrayOfContentParts[0].OfText.Text)
+}
+
+func TestMessagesToOpenAI_Reasoning(t *testing.T) {
+	t.Parallel()
+
+	messages, err := aisdk.MessagesToOpenAI([]aisdk.Message{{
+		Role: "assistant",
+		Parts: []aisdk.Part{{
+			Type:      aisdk.PartTypeReasoning,
+			Reasoning: "The user said hi.",
+			Details:   []aisdk.ReasoningDetail{{Type: "text", Text: "The user said hi.", Signature: "sig"}},
+		}, {
+			Type: aisdk.PartTypeText,
+			Text: "Hello!",
+		}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	content := messages[0].OfAssistant.Content.OfArrayOfContentParts
+	require.Len(t, content, 1)
+	require.Equal(t, "Hello!", content[0].OfText.Text)
+}
+
+func TestMessagesToOpenAI_Developer(t *testing.T) {
+	t.Parallel()
+
+	messages, err := aisdk.MessagesToOpenAI([]aisdk.Message{{
+		Role:    "developer",
+		Content: "You are a helpful assistant.",
+	}})
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	require.Nil(t, messages[0].OfSystem)
+	require.NotNil(t, messages[0].OfDeveloper)
+	require.Equal(t, "You are a helpful assistant.", messages[0].OfDeveloper.Content.OfString.Value)
+}
+
+func TestMessagesToOpenAI_Empty(t *testing.T) {
+	t.Parallel()
+
+	_, err := aisdk.MessagesToOpenAI(nil)
+	require.EqualError(t, err, "at least one message required")
+}
+
+func TestMessagesToOpenAI_UnsupportedImageType(t *testing.T) {
+	t.Parallel()
+
+	_, err := aisdk.MessagesToOpenAI([]aisdk.Message{{
+		Role:  "user",
+		Parts: []aisdk.Part{{Type: aisdk.PartTypeFile, MimeType: "image/bmp", Data: []byte("bmp")}},
+	}})
+	require.EqualError(t, err, `unsupported image type "image/bmp" for OpenAI, supported types are image/png, image/jpeg, image/gif, image/webp`)
+
+	_, err = aisdk.MessagesToOpenAI([]aisdk.Message{{
+		Role:        "user",
+		Attachments: []aisdk.Attachment{{ContentType: "image/tiff", URL: "data:image/tiff;base64,dGlmZg=="}},
+	}})
+	require.ErrorContains(t, err, `"image/tiff"`)
+}
+
+func TestOpenAIToDataStream_Error(t *testing.T) {
+	t.Parallel()
+
+	setupErr := errors.New("401 Unauthorized")
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](nil, setupErr)
+
+	var streamErr error
+	for part, err := range aisdk.OpenAIToDataStream(typedStream) {
+		require.Nil(t, part)
+		streamErr = err
+	}
+
+	var providerErr *aisdk.ProviderError
+	require.ErrorAs(t, streamErr, &providerErr)
+	require.Equal(t, "openai", providerErr.Provider)
+	require.ErrorIs(t, streamErr, setupErr)
+}
+
+func TestOpenAIResponseToMessage(t *testing.T) {
+	t.Parallel()
+
+	var resp openai.ChatCompletion
+	err := json.Unmarshal([]byte(`{"id":"chatcmpl-1","object":"chat.completion","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Let me print that.","refusal":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"print","arguments":"{\"message\":\"Hello\"}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":20,"completion_tokens":10,"total_tokens":30}}`), &resp)
+	require.NoError(t, err)
+
+	message, err := aisdk.OpenAIResponseToMessage(resp)
+	require.NoError(t, err)
+	require.Equal(t, "assistant", message.Role)
+	require.Equal(t, "Let me print that.", message.TextContent())
+	require.Len(t, message.Parts, 2)
+	require.Equal(t, &aisdk.ToolInvocation{
+		State:      aisdk.ToolInvocationStateCall,
+		Step:       intPtr(0),
+		ToolCallID: "call_1",
+		ToolName:   "print",
+		Args:       map[string]any{"message": "Hello"},
+	}, message.Parts[1].ToolInvocation)
+}
+
+func TestOpenAIMultipleChoices(t *testing.T) {
+	t.Parallel()
+
+	mockResponse := `data: {"id":"chatcmpl-n","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Heads"},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-n","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":1,"delta":{"role":"assistant","content":"Tails"},"finish_reason":null}]}
+
+data: [DONE]`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(mockResponse)),
+	})
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+	var streamErr error
+	for _, err := range aisdk.OpenAIToDataStream(typedStream) {
+		streamErr = err
+	}
+	require.EqualError(t, streamErr, "openai stream error: received choice 1, but only a single choice is supported, request n = 1")
+	var providerErr *aisdk.ProviderError
+	require.ErrorAs(t, streamErr, &providerErr)
+	require.Equal(t, "openai", providerErr.Provider)
+
+	var resp openai.ChatCompletion
+	err := json.Unmarshal([]byte(`{"id":"chatcmpl-n","object":"chat.completion","created":1744123083,"model":"gpt-4o","choices":[{"index":1,"message":{"role":"assistant","content":"Tails","refusal":null},"finish_reason":"stop"},{"index":0,"message":{"role":"assistant","content":"Heads","refusal":null},"finish_reason":"stop"}],"usage":{"prompt_tokens":20,"completion_tokens":2,"total_tokens":22}}`), &resp)
+	require.NoError(t, err)
+
+	messages, err := aisdk.OpenAIResponseToMessages(resp)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "Heads", messages[0].TextContent())
+	require.Equal(t, "Tails", messages[1].TextContent())
+}
+
+func TestOpenAIToDataStream_UnknownToolCallIndex(t *testing.T) {
+	t.Parallel()
+
+	mockResponse := `data: {"id":"chatcmpl-u","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":1,"function":{"arguments":"{\"a\":1}"}}]},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-u","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"Hello"},"finish_reason":null}]}
+
+data: [DONE]`
+
+	decoder := ssestream.NewDecoder(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(mockResponse)),
+	})
+	typedStream := ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+
+	var errs []error
+	for part, err := range aisdk.OpenAIToDataStream(typedStream) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		_, isText := part.(aisdk.TextStreamPart)
+		require.False(t, isText, "the stream must end at the error")
+	}
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], "openai stream error: received tool call delta with empty ID and no tool call at index 1")
+}
+
+func TestOpenAIToDataStream_ToolCallSteps(t *testing.T) {
+	t.Parallel()
+
+	toolCallResponse := `data: {"id":"chatcmpl-step1","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":null,"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"location\":\"Paris\"}"}}]},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-step1","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: [DONE]`
+	answerResponse := `data: {"id":"chatcmpl-step2","object":"chat.completion.chunk","created":1744123084,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"It's sunny in Paris."},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-step2","object":"chat.completion.chunk","created":1744123084,"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: [DONE]`
+
+	openAIStream := func(response string) aisdk.DataStream {
+		decoder := ssestream.NewDecoder(&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(response)),
+		})
+		return aisdk.OpenAIToDataStream(ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil))
+	}
+	handler := func(toolCall aisdk.ToolCall) any {
+		return "sunny"
+	}
+
+	// The first step of an agent loop: the step finishes with the tool call,
+	// and the message finishes with it.
+	var acc aisdk.DataStreamAccumulator
+	require.NoError(t, openAIStream(toolCallResponse).WithToolCalling(handler).WithAccumulator(&acc).Drain())
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, aisdk.FinishReasonToolCalls, acc.FinishReason())
+	parts := acc.Messages()[0].Parts
+	require.Len(t, parts, 1)
+	require.Equal(t, aisdk.ToolInvocationStateResult, parts[0].ToolInvocation.State)
+	require.Equal(t, "sunny", parts[0].ToolInvocation.Result)
+
+	// Continuing the loop in the same message, the answer is appended as
+	// the next step instead of starting another message.
+	first := openAIStream(toolCallResponse).WithToolCalling(handler).Filter(func(part aisdk.DataStreamPart) bool {
+		_, isFinish := part.(aisdk.FinishMessageStreamPart)
+		return !isFinish
+	})
+	second := openAIStream(answerResponse)
+	acc = aisdk.DataStreamAccumulator{}
+	for _, stream := range []aisdk.DataStream{first, second} {
+		require.NoError(t, stream.WithAccumulator(&acc).Drain())
+	}
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, aisdk.FinishReasonStop, acc.FinishReason())
+	require.Equal(t, "It's sunny in Paris.", acc.Messages()[0].Content)
+	require.Len(t, acc.Steps(), 2)
+}
+
+// newRateLimitServer returns a server that rate limits every request.
+func newRateLimitServer(t *testing.T, header, value string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(header, value)
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = fmt.Fprint(w, `{"error":{"type":"rate_limit_error","message":"Rate limit exceeded"}}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRateLimitError_OpenAI(t *testing.T) {
+	t.Parallel()
+
+	server := newRateLimitServer(t, "Retry-After", "2")
+	client := openai.NewClient(
+		option.WithBaseURL(server.URL+"/v1/"),
+		option.WithAPIKey("sk-test"),
+		option.WithMaxRetries(0),
+	)
+	stream := client.Chat.Completions.NewStreaming(context.Background(), openai.ChatCompletionNewParams{
+		Model:    openai.ChatModelGPT4o,
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	})
+
+	var streamErr error
+	for _, err := range aisdk.OpenAIToDataStream(stream) {
+		streamErr = err
+	}
+
+	var rateLimitErr *aisdk.RateLimitError
+	require.ErrorAs(t, streamErr, &rateLimitErr)
+	require.Equal(t, 2*time.Second, rateLimitErr.RetryAfter)
+
+	var apiErr *openai.Error
+	require.ErrorAs(t, streamErr, &apiErr)
+}
@@ -0,0 +1,113 @@
+package aisdk
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseDataStream reads the v1 data stream protocol, as written by Pipe, from r
+// and yields its parts. Malformed lines and unknown TYPE_IDs are yielded as an
+// error and end the stream.
+//
+// Parts that share a TYPE_ID are parsed as the part the protocol defines for
+// it: a CursorStreamPart is yielded as a DataStreamDataPart and a
+// RefusalStreamPart as an ErrorStreamPart.
+func ParseDataStream(r io.Reader) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		// Lines are read without a length limit, since file parts can be large.
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				yield(nil, err)
+				return
+			}
+			if line = strings.TrimRight(line, "\r\n"); line != "" {
+				part, parseErr := parseDataStreamPart(line)
+				if parseErr != nil {
+					yield(nil, parseErr)
+					return
+				}
+				if !yield(part, nil) {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// parseDataStreamPart parses a single `TYPE_ID:JSON` line.
+func parseDataStreamPart(line string) (DataStreamPart, error) {
+	typeID, data, ok := strings.Cut(line, ":")
+	if !ok || len(typeID) != 1 {
+		return nil, fmt.Errorf("invalid data stream line: %q", line)
+	}
+
+	var part DataStreamPart
+	var err error
+	switch typeID[0] {
+	case '0':
+		var content string
+		err = json.Unmarshal([]byte(data), &content)
+		part = TextStreamPart{Content: content}
+	case 'g':
+		var content string
+		err = json.Unmarshal([]byte(data), &content)
+		part = ReasoningStreamPart{Content: content}
+	case '3':
+		var content string
+		err = json.Unmarshal([]byte(data), &content)
+		part = ErrorStreamPart{Content: content}
+	case '2':
+		var content []any
+		err = json.Unmarshal([]byte(data), &content)
+		part = DataStreamDataPart{Content: content}
+	case '8':
+		var content []any
+		err = json.Unmarshal([]byte(data), &content)
+		part = MessageAnnotationStreamPart{Content: content}
+	case 'i':
+		part, err = unmarshalPart[RedactedReasoningStreamPart](data)
+	case 'j':
+		part, err = unmarshalPart[ReasoningSignatureStreamPart](data)
+	case 'h':
+		part, err = unmarshalPart[SourceStreamPart](data)
+	case 'k':
+		part, err = unmarshalPart[FileStreamPart](data)
+	case '9':
+		part, err = unmarshalPart[ToolCallStreamPart](data)
+	case 'a':
+		part, err = unmarshalPart[ToolResultStreamPart](data)
+	case 'b':
+		part, err = unmarshalPart[ToolCallStartStreamPart](data)
+	case 'c':
+		part, err = unmarshalPart[ToolCallDeltaStreamPart](data)
+	case 'f':
+		part, err = unmarshalPart[StartStepStreamPart](data)
+	case 'e':
+		part, err = unmarshalPart[FinishStepStreamPart](data)
+	case 'd':
+		part, err = unmarshalPart[FinishMessageStreamPart](data)
+	default:
+		return nil, fmt.Errorf("unknown data stream part type %q", typeID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse data stream part %q: %w", typeID, err)
+	}
+	return part, nil
+}
+
+func unmarshalPart[T DataStreamPart](data string) (DataStreamPart, error) {
+	var part T
+	if err := json.Unmarshal([]byte(data), &part); err != nil {
+		return nil, err
+	}
+	return part, nil
+}
@@ -0,0 +1,50 @@
+package aisdk_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDataStream(t *testing.T) {
+	t.Parallel()
+
+	parts := []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ReasoningStreamPart{Content: "Weather question."},
+		aisdk.ReasoningSignatureStreamPart{Signature: "sig"},
+		aisdk.TextStreamPart{Content: "Let me check <the weather>."},
+		aisdk.SourceStreamPart{SourceType: "url", ID: "1", URL: "https://example.com", Title: "Example"},
+		aisdk.FileStreamPart{Data: []byte("png"), MimeType: "image/png"},
+		aisdk.ToolCallStartStreamPart{ToolCallID: "tool_1", ToolName: "get_weather"},
+		aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `{"location":"Paris"}`},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "get_weather", Args: map[string]any{"location": "Paris"}},
+		aisdk.ToolResultStreamPart{ToolCallID: "tool_1", Result: "sunny"},
+		aisdk.DataStreamDataPart{Content: []any{map[string]any{"progress": 1.0}}},
+		aisdk.MessageAnnotationStreamPart{Content: []any{"note"}},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls, Usage: aisdk.Usage{PromptTokens: int64Ptr(10), CompletionTokens: int64Ptr(5)}},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls, Usage: aisdk.Usage{PromptTokens: int64Ptr(10), CompletionTokens: int64Ptr(5)}},
+	}
+
+	var recorded bytes.Buffer
+	for _, err := range partsStream(parts...).Record(&recorded) {
+		require.NoError(t, err)
+	}
+
+	var parsed []aisdk.DataStreamPart
+	for part, err := range aisdk.ParseDataStream(&recorded) {
+		require.NoError(t, err)
+		parsed = append(parsed, part)
+	}
+	require.Equal(t, parts, parsed)
+
+	for _, input := range []string{"0:\"unterminated", "z:{}", "no type id"} {
+		var err error
+		for _, err = range aisdk.ParseDataStream(strings.NewReader(input)) {
+		}
+		require.Error(t, err, input)
+	}
+}
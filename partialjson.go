@@ -0,0 +1,82 @@
+package aisdk
+
+// parsePartialJSON parses an incomplete JSON object as it arrives in a stream.
+// Open strings, arrays and objects are closed, and trailing values that cannot
+// be completed (e.g. a key without a value or a partial literal) are dropped.
+// It reports false if no object can be recovered yet. With useNumber, numbers
+// are decoded as json.Number.
+func parsePartialJSON(text string, useNumber bool) (map[string]any, bool) {
+	for {
+		closed, boundary := closePartialJSON(text)
+
+		if obj, err := decodeToolArgs(closed, useNumber); err == nil {
+			return obj, true
+		}
+		if boundary < 0 {
+			return nil, false
+		}
+
+		// Drop the trailing value and try again.
+		truncated := text[:boundary+1]
+		if text[boundary] == ',' {
+			truncated = text[:boundary]
+		}
+		if truncated == text {
+			return nil, false
+		}
+		text = truncated
+	}
+}
+
+// closePartialJSON returns text with its open strings, arrays and objects
+// closed, and the index of the last ',', '{' or '[' outside of a string, or -1
+// if there is none.
+func closePartialJSON(text string) (closed string, boundary int) {
+	var stack []byte
+	var inString, escaped bool
+	boundary = -1
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+			boundary = i
+		case '[':
+			stack = append(stack, ']')
+			boundary = i
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			boundary = i
+		}
+	}
+
+	closed = text
+	if inString {
+		if escaped {
+			// Drop the dangling escape character.
+			closed = closed[:len(closed)-1]
+		}
+		closed += `"`
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		closed += string(stack[i])
+	}
+	return closed, boundary
+}
@@ -0,0 +1,12 @@
+package aisdk
+
+import (
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// PerplexityToDataStream pipes a Perplexity stream to a DataStream.
+// Perplexity's citations are emitted as SourceStreamParts.
+func PerplexityToDataStream(stream *ssestream.Stream[openai.ChatCompletionChunk]) DataStream {
+	return openAIToDataStream("perplexity", stream)
+}
@@ -0,0 +1,50 @@
+package aisdk
+
+import (
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	openaioption "github.com/openai/openai-go/option"
+)
+
+// ProxyConfig routes provider clients through an API-compatible proxy,
+// such as LiteLLM, that is shared by every provider.
+//
+// The converters and *ToDataStream functions don't depend on the endpoint
+// the stream came from, so they work unchanged behind a proxy:
+//
+//	proxy := aisdk.ProxyConfig{
+//		BaseURL: "https://litellm.internal/",
+//		Headers: map[string]string{"Authorization": "Bearer sk-virtual-key"},
+//	}
+//	client := openai.NewClient(proxy.OpenAIOptions()...)
+type ProxyConfig struct {
+	// BaseURL is the URL of the proxy. It replaces the provider's API URL.
+	BaseURL string
+	// Headers are set on every request, e.g. virtual keys or tags.
+	Headers map[string]string
+}
+
+// OpenAIOptions returns the request options to configure an OpenAI client
+// to send requests through the proxy.
+func (c ProxyConfig) OpenAIOptions() []openaioption.RequestOption {
+	opts := []openaioption.RequestOption{}
+	if c.BaseURL != "" {
+		opts = append(opts, openaioption.WithBaseURL(c.BaseURL))
+	}
+	for key, value := range c.Headers {
+		opts = append(opts, openaioption.WithHeader(key, value))
+	}
+	return opts
+}
+
+// AnthropicOptions returns the request options to configure an Anthropic client
+// to send requests through the proxy.
+func (c ProxyConfig) AnthropicOptions() []anthropicoption.RequestOption {
+	opts := []anthropicoption.RequestOption{}
+	if c.BaseURL != "" {
+		opts = append(opts, anthropicoption.WithBaseURL(c.BaseURL))
+	}
+	for key, value := range c.Headers {
+		opts = append(opts, anthropicoption.WithHeader(key, value))
+	}
+	return opts
+}
@@ -0,0 +1,112 @@
+package aisdk_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/morecommits/aisdk-go"
+	"github.com/openai/openai-go"
+	openaioption "github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/require"
+)
+
+// newProxyServer returns a server that behaves like a LiteLLM proxy: it
+// requires the configured headers and responds with a reshaped model name.
+func newProxyServer(t *testing.T, path string, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.Error(w, "unexpected path "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-Litellm-Tags") != "team-a" {
+			http.Error(w, "missing tags header", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxyConfig_OpenAI(t *testing.T) {
+	t.Parallel()
+
+	server := newProxyServer(t, "/v1/chat/completions", `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"litellm/team-a/gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello from the proxy"},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"litellm/team-a/gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: [DONE]
+
+`)
+	proxy := aisdk.ProxyConfig{
+		BaseURL: server.URL + "/v1/",
+		Headers: map[string]string{"X-Litellm-Tags": "team-a"},
+	}
+	client := openai.NewClient(append(proxy.OpenAIOptions(), openaioption.WithAPIKey("sk-virtual-key"))...)
+
+	stream := client.Chat.Completions.NewStreaming(context.Background(), openai.ChatCompletionNewParams{
+		Model:    "team-a/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	})
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range aisdk.OpenAIToDataStream(stream).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, "Hello from the proxy", acc.Messages()[0].Content)
+	require.Equal(t, aisdk.FinishReasonStop, acc.FinishReason())
+}
+
+func TestProxyConfig_Anthropic(t *testing.T) {
+	t.Parallel()
+
+	server := newProxyServer(t, "/anthropic/v1/messages", `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"litellm/team-a/claude","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello from the proxy"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":5}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`)
+	proxy := aisdk.ProxyConfig{
+		BaseURL: server.URL + "/anthropic/",
+		Headers: map[string]string{"X-Litellm-Tags": "team-a"},
+	}
+	client := anthropic.NewClient(append(proxy.AnthropicOptions(), anthropicoption.WithAPIKey("sk-virtual-key"))...)
+
+	stream := client.Messages.NewStreaming(context.Background(), anthropic.MessageNewParams{
+		Model:     "team-a/claude",
+		MaxTokens: 10,
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock("Hello")),
+		},
+	})
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range aisdk.AnthropicToDataStream(stream).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, "msg_1", acc.Messages()[0].ID)
+	require.Equal(t, "Hello from the proxy", acc.Messages()[0].Content)
+	require.Equal(t, aisdk.FinishReasonStop, acc.FinishReason())
+}
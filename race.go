@@ -0,0 +1,186 @@
+package aisdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// RaceDataStreams yields the parts of whichever stream produces its first
+// content part (text, reasoning, a tool call, a source or a file) first, and
+// stops the others. Parts a stream yields before its first content part, like
+// StartStepStreamPart, are held back until it wins.
+//
+// Each stream is started by its factory with its own context, derived from ctx,
+// and iterated in its own goroutine. The context of a losing stream is
+// canceled as soon as the race is won, so that its request stops, and its
+// goroutine exits without being drained. The contexts of all streams are
+// canceled once the race stream is done.
+//
+// If every stream fails before producing content, the errors are joined. If
+// every stream ends without content, the first stream that ended cleanly is
+// yielded.
+func RaceDataStreams(ctx context.Context, streams ...func(ctx context.Context) DataStream) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		type item struct {
+			index int
+			part  DataStreamPart
+			err   error
+			end   bool
+		}
+
+		items := make(chan item)
+		stops := make([]chan struct{}, len(streams))
+		cancels := make([]context.CancelFunc, len(streams))
+		contexts := make([]context.Context, len(streams))
+		for i := range stops {
+			stops[i] = make(chan struct{})
+			contexts[i], cancels[i] = context.WithCancel(ctx)
+		}
+		stopped := make([]bool, len(streams))
+		stop := func(i int) {
+			if !stopped[i] {
+				stopped[i] = true
+				close(stops[i])
+				cancels[i]()
+			}
+		}
+		defer func() {
+			for i := range streams {
+				stop(i)
+			}
+		}()
+
+		for i, newStream := range streams {
+			go func() {
+				for part, err := range newStream(contexts[i]) {
+					select {
+					case items <- item{index: i, part: part, err: err}:
+					case <-stops[i]:
+						return
+					}
+					if err != nil {
+						return
+					}
+				}
+				select {
+				case items <- item{index: i, end: true}:
+				case <-stops[i]:
+				}
+			}()
+		}
+
+		winner := -1
+		pending := make([][]DataStreamPart, len(streams))
+		var errs []error
+		ended := -1
+		remaining := len(streams)
+
+		for remaining > 0 {
+			it := <-items
+			if stopped[it.index] {
+				// A loser that was stopped while sending.
+				continue
+			}
+
+			if winner >= 0 {
+				if it.end {
+					return
+				}
+				if !yield(it.part, it.err) || it.err != nil {
+					return
+				}
+				continue
+			}
+
+			switch {
+			case it.err != nil:
+				errs = append(errs, it.err)
+				stop(it.index)
+				remaining--
+				continue
+			case it.end:
+				if ended < 0 {
+					ended = it.index
+				}
+				stop(it.index)
+				remaining--
+				continue
+			}
+
+			pending[it.index] = append(pending[it.index], it.part)
+			if !isContentPart(it.part) {
+				continue
+			}
+
+			winner = it.index
+			for i := range streams {
+				if i != winner {
+					stop(i)
+				}
+			}
+			for _, part := range pending[winner] {
+				if !yield(part, nil) {
+					return
+				}
+			}
+		}
+
+		if ended >= 0 {
+			for _, part := range pending[ended] {
+				if !yield(part, nil) {
+					return
+				}
+			}
+			return
+		}
+		if len(errs) > 0 {
+			yield(nil, errors.Join(errs...))
+		}
+	}
+}
+
+// isContentPart returns true if the part is generated content, as opposed to
+// metadata like steps and usage.
+func isContentPart(part DataStreamPart) bool {
+	switch part.(type) {
+	case TextStreamPart, ReasoningStreamPart, ToolCallStartStreamPart, ToolCallStreamPart, SourceStreamPart, FileStreamPart:
+		return true
+	}
+	return false
+}
+
+// DataStreamResult is the outcome of a stream run by CollectDataStreams.
+type DataStreamResult struct {
+	Messages []Message
+	Usage    Usage
+	Err      error
+}
+
+// CollectDataStreams runs all streams concurrently to completion and returns
+// their accumulated results in the order of the streams, e.g. to compare the
+// answers of several providers to the same prompt.
+func CollectDataStreams(streams ...DataStream) []DataStreamResult {
+	results := make([]DataStreamResult, len(streams))
+
+	var wg sync.WaitGroup
+	for i, stream := range streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var acc DataStreamAccumulator
+			for _, err := range stream.WithAccumulator(&acc) {
+				if err != nil {
+					results[i].Err = err
+					break
+				}
+			}
+			results[i].Messages = acc.Messages()
+			results[i].Usage = acc.TotalUsage()
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
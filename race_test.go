@@ -0,0 +1,96 @@
+package aisdk_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRaceDataStreams(t *testing.T) {
+	t.Parallel()
+
+	// The slow stream starts a step right away, but its content is held
+	// back until its request is canceled, like a pending HTTP request.
+	slowCtx := make(chan context.Context, 1)
+	slow := func(ctx context.Context) aisdk.DataStream {
+		slowCtx <- ctx
+		return func(yield func(aisdk.DataStreamPart, error) bool) {
+			if !yield(aisdk.StartStepStreamPart{MessageID: "slow"}, nil) {
+				return
+			}
+			<-ctx.Done()
+			yield(nil, ctx.Err())
+		}
+	}
+	fast := func(ctx context.Context) aisdk.DataStream {
+		return partsStream(
+			aisdk.StartStepStreamPart{MessageID: "fast"},
+			aisdk.TextStreamPart{Content: "Hello"},
+			aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+		)
+	}
+
+	var parts []aisdk.DataStreamPart
+	for part, err := range aisdk.RaceDataStreams(context.Background(), slow, fast) {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+	require.Equal(t, []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "fast"},
+		aisdk.TextStreamPart{Content: "Hello"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	}, parts)
+
+	// The context of the losing stream must be canceled.
+	select {
+	case <-(<-slowCtx).Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow stream was not canceled")
+	}
+}
+
+func TestRaceDataStreams_Errors(t *testing.T) {
+	t.Parallel()
+
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+	failing := func(err error) func(context.Context) aisdk.DataStream {
+		return func(context.Context) aisdk.DataStream {
+			return func(yield func(aisdk.DataStreamPart, error) bool) {
+				yield(nil, err)
+			}
+		}
+	}
+
+	var streamErr error
+	for _, err := range aisdk.RaceDataStreams(context.Background(), failing(errA), failing(errB)) {
+		streamErr = err
+	}
+	require.ErrorIs(t, streamErr, errA)
+	require.ErrorIs(t, streamErr, errB)
+}
+
+func TestCollectDataStreams(t *testing.T) {
+	t.Parallel()
+
+	results := aisdk.CollectDataStreams(
+		partsStream(
+			aisdk.StartStepStreamPart{MessageID: "msg_1"},
+			aisdk.TextStreamPart{Content: "Hello"},
+			aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+		),
+		func(yield func(aisdk.DataStreamPart, error) bool) {
+			yield(nil, errors.New("failed"))
+		},
+	)
+
+	require.Len(t, results, 2)
+	require.NoError(t, results[0].Err)
+	require.Len(t, results[0].Messages, 1)
+	require.Equal(t, "Hello", results[0].Messages[0].Content)
+	require.EqualError(t, results[1].Err, "failed")
+}
@@ -0,0 +1,130 @@
+package aisdk
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicssestream "github.com/anthropics/anthropic-sdk-go/packages/ssestream"
+	"github.com/openai/openai-go"
+	openaissestream "github.com/openai/openai-go/packages/ssestream"
+)
+
+// ReplayOpenAI returns a DataStream of pre-recorded OpenAI chunks, as if they
+// were streamed by the API. This is useful to replay interactions stored as a
+// JSON array of chunks in tests. The returned DataStream can be iterated
+// multiple times.
+func ReplayOpenAI(chunks []openai.ChatCompletionChunk) DataStream {
+	events := make([]openaissestream.Event, 0, len(chunks))
+	for _, chunk := range chunks {
+		data, err := replayData(chunk.RawJSON(), chunk)
+		if err != nil {
+			return replayError(err)
+		}
+		events = append(events, openaissestream.Event{Data: data})
+	}
+	return func(yield func(DataStreamPart, error) bool) {
+		decoder := &openAIReplayDecoder{events: events}
+		OpenAIToDataStream(openaissestream.NewStream[openai.ChatCompletionChunk](decoder, nil))(yield)
+	}
+}
+
+// ReplayAnthropic returns a DataStream of pre-recorded Anthropic events, as if
+// they were streamed by the API. This is useful to replay interactions stored
+// as a JSON array of events in tests. The returned DataStream can be iterated
+// multiple times.
+func ReplayAnthropic(events []anthropic.MessageStreamEventUnion) DataStream {
+	sseEvents := make([]anthropicssestream.Event, 0, len(events))
+	for _, event := range events {
+		data, err := replayData(event.RawJSON(), event)
+		if err != nil {
+			return replayError(err)
+		}
+		sseEvents = append(sseEvents, anthropicssestream.Event{Type: event.Type, Data: data})
+	}
+	return func(yield func(DataStreamPart, error) bool) {
+		decoder := &anthropicReplayDecoder{events: sseEvents}
+		AnthropicToDataStream(anthropicssestream.NewStream[anthropic.MessageStreamEventUnion](decoder, nil))(yield)
+	}
+}
+
+// replayData returns the raw JSON a chunk was decoded from, falling back
+// to marshaling chunks that were constructed in code.
+func replayData(raw string, chunk any) ([]byte, error) {
+	if raw != "" {
+		return []byte(raw), nil
+	}
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal replay chunk: %w", err)
+	}
+	return data, nil
+}
+
+func replayError(err error) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		yield(nil, err)
+	}
+}
+
+// openAIReplayDecoder decodes events from memory instead of an HTTP response.
+type openAIReplayDecoder struct {
+	events []openaissestream.Event
+	index  int
+}
+
+func (d *openAIReplayDecoder) Next() bool {
+	if d.index >= len(d.events) {
+		return false
+	}
+	d.index++
+	return true
+}
+
+func (d *openAIReplayDecoder) Event() openaissestream.Event { return d.events[d.index-1] }
+func (d *openAIReplayDecoder) Close() error                 { return nil }
+func (d *openAIReplayDecoder) Err() error                   { return nil }
+
+// anthropicReplayDecoder decodes events from memory instead of an HTTP response.
+type anthropicReplayDecoder struct {
+	events []anthropicssestream.Event
+	index  int
+}
+
+func (d *anthropicReplayDecoder) Next() bool {
+	if d.index >= len(d.events) {
+		return false
+	}
+	d.index++
+	return true
+}
+
+func (d *anthropicReplayDecoder) Event() anthropicssestream.Event { return d.events[d.index-1] }
+func (d *anthropicReplayDecoder) Close() error                    { return nil }
+func (d *anthropicReplayDecoder) Err() error                      { return nil }
+
+// BufferDataStream drains s into memory and returns a DataStream that replays
+// its parts, and errors, in the same order every time it's iterated, e.g. to
+// feed the same response to several accumulators. The returned error is the
+// last error s yielded, which every replay yields as well.
+func BufferDataStream(s DataStream) (DataStream, error) {
+	type entry struct {
+		part DataStreamPart
+		err  error
+	}
+	var entries []entry
+	var lastErr error
+	for part, err := range s {
+		entries = append(entries, entry{part: part, err: err})
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return func(yield func(DataStreamPart, error) bool) {
+		for _, e := range entries {
+			if !yield(e.part, e.err) {
+				return
+			}
+		}
+	}, lastErr
+}
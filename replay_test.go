@@ -0,0 +1,99 @@
+package aisdk_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/morecommits/aisdk-go"
+	"github.com/morecommits/aisdk-go/aisdktest"
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayOpenAI(t *testing.T) {
+	t.Parallel()
+
+	recorded := `[
+		{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"},"finish_reason":null}]},
+		{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":", world!"},"finish_reason":null}]},
+		{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]},
+		{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":4,"total_tokens":13}}
+	]`
+	var chunks []openai.ChatCompletionChunk
+	require.NoError(t, json.Unmarshal([]byte(recorded), &chunks))
+
+	// A replay can be iterated more than once.
+	stream := aisdk.ReplayOpenAI(chunks)
+	for range 2 {
+		var acc aisdk.DataStreamAccumulator
+		for _, err := range stream.WithAccumulator(&acc) {
+			require.NoError(t, err)
+		}
+		require.Len(t, acc.Messages(), 1)
+		require.Equal(t, "Hello, world!", acc.Messages()[0].Content)
+		require.Equal(t, aisdk.FinishReasonStop, acc.FinishReason())
+		require.Equal(t, int64(13), acc.Usage().PromptTokensOrZero()+acc.Usage().CompletionTokensOrZero())
+	}
+}
+
+func TestReplayAnthropic(t *testing.T) {
+	t.Parallel()
+
+	recorded := `[
+		{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":9,"output_tokens":1}}},
+		{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}},
+		{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello, world!"}},
+		{"type":"content_block_stop","index":0},
+		{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":4}},
+		{"type":"message_stop"}
+	]`
+	var events []anthropic.MessageStreamEventUnion
+	require.NoError(t, json.Unmarshal([]byte(recorded), &events))
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range aisdk.ReplayAnthropic(events).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, "msg_1", acc.Messages()[0].ID)
+	require.Equal(t, "Hello, world!", acc.Messages()[0].Content)
+	require.Equal(t, aisdk.FinishReasonStop, acc.FinishReason())
+}
+
+func TestBufferDataStream(t *testing.T) {
+	t.Parallel()
+
+	var pulls int
+	source := aisdk.DataStream(func(yield func(aisdk.DataStreamPart, error) bool) {
+		pulls++
+		if !yield(aisdk.StartStepStreamPart{MessageID: "msg_1"}, nil) {
+			return
+		}
+		if !yield(aisdk.TextStreamPart{Content: "Hello"}, nil) {
+			return
+		}
+		yield(nil, errors.New("connection reset"))
+	})
+
+	buffered, err := aisdk.BufferDataStream(source)
+	require.EqualError(t, err, "connection reset")
+
+	for range 2 {
+		var acc aisdk.DataStreamAccumulator
+		require.EqualError(t, buffered.WithAccumulator(&acc).Drain(), "connection reset")
+		message, ok := acc.CurrentMessage()
+		require.True(t, ok)
+		require.Equal(t, "Hello", message.Content)
+	}
+	require.Equal(t, 1, pulls)
+
+	// Stopping a replay early doesn't affect the next one.
+	for range buffered {
+		break
+	}
+	parts, err := aisdktest.CollectParts(buffered)
+	require.EqualError(t, err, "connection reset")
+	require.Len(t, parts, 2)
+}
@@ -0,0 +1,147 @@
+package aisdk
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrCursorExpired is returned by ResumeBuffer.ResumeFrom when the parts after
+// the cursor are no longer buffered.
+var ErrCursorExpired = errors.New("cursor expired")
+
+// ErrStreamAborted is yielded by a resumed stream whose source stopped being
+// consumed before it ended, so the response was cut off.
+var ErrStreamAborted = errors.New("stream aborted")
+
+// CursorStreamPart carries the cursor to resume a stream from after a
+// reconnect. It is sent as a data part, and ignored by the accumulator.
+type CursorStreamPart struct {
+	Cursor int
+}
+
+func (p CursorStreamPart) TypeID() byte { return '2' }
+func (p CursorStreamPart) Format() (string, error) {
+	return formatJSONPart(p, true)
+}
+func (p CursorStreamPart) jsonValue() any {
+	return []any{map[string]any{
+		"type":   "cursor",
+		"cursor": p.Cursor,
+	}}
+}
+
+// ResumeBuffer buffers the most recent parts of a stream, so that a client that
+// reconnects can resume where it left off. It is safe for concurrent use.
+type ResumeBuffer struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	size   int
+	parts  []DataStreamPart // The most recent parts, ending at next.
+	next   int              // Cursor of the next part.
+	closed bool
+	err    error
+}
+
+// NewResumeBuffer returns a ResumeBuffer that keeps the last size parts.
+func NewResumeBuffer(size int) *ResumeBuffer {
+	b := &ResumeBuffer{size: size}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// WithResumeBuffer stores every part in the buffer and follows it with a
+// CursorStreamPart, so that the client knows where to resume from. The buffer
+// is closed once the stream ends. If the consumer stops early, the buffer is
+// closed with ErrStreamAborted, so that resumed streams can tell the response
+// was cut off.
+//
+// Stopping early stops generation, so piping this stream straight to the
+// client's connection doesn't help a client that reconnects: the drop ends
+// the response. To resume, consume this stream independently of the
+// connection, e.g. in a goroutine, and serve every client from ResumeFrom.
+func (s DataStream) WithResumeBuffer(buf *ResumeBuffer) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		for part, err := range s {
+			if err != nil {
+				buf.close(err)
+				yield(nil, err)
+				return
+			}
+			cursor := buf.append(part)
+			if !yield(part, nil) || !yield(CursorStreamPart{Cursor: cursor}, nil) {
+				buf.close(ErrStreamAborted)
+				return
+			}
+		}
+		buf.close(nil)
+	}
+}
+
+// ResumeFrom returns the parts from cursor on, followed by the parts that are
+// still being generated, each stamped with its cursor like WithResumeBuffer.
+// It returns ErrCursorExpired if the parts are no longer buffered.
+func (b *ResumeBuffer) ResumeFrom(cursor int) (DataStream, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cursor > b.next || cursor < 0 {
+		return nil, fmt.Errorf("invalid cursor %d", cursor)
+	}
+	if cursor < b.next-len(b.parts) {
+		return nil, ErrCursorExpired
+	}
+
+	return func(yield func(DataStreamPart, error) bool) {
+		for {
+			b.mu.Lock()
+			for cursor == b.next && !b.closed {
+				b.cond.Wait()
+			}
+			if cursor < b.next-len(b.parts) {
+				// The parts were evicted while the consumer was blocked.
+				b.mu.Unlock()
+				yield(nil, ErrCursorExpired)
+				return
+			}
+			if cursor == b.next {
+				err := b.err
+				b.mu.Unlock()
+				if err != nil {
+					yield(nil, err)
+				}
+				return
+			}
+			part := b.parts[len(b.parts)-(b.next-cursor)]
+			b.mu.Unlock()
+
+			cursor++
+			if !yield(part, nil) || !yield(CursorStreamPart{Cursor: cursor}, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+// append buffers the part and returns the cursor after it.
+func (b *ResumeBuffer) append(part DataStreamPart) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.parts = append(b.parts, part)
+	if len(b.parts) > b.size {
+		b.parts = b.parts[len(b.parts)-b.size:]
+	}
+	b.next++
+	b.cond.Broadcast()
+	return b.next
+}
+
+func (b *ResumeBuffer) close(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	b.err = err
+	b.cond.Broadcast()
+}
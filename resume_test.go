@@ -0,0 +1,102 @@
+package aisdk_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeBuffer(t *testing.T) {
+	t.Parallel()
+
+	buf := aisdk.NewResumeBuffer(3)
+	source := make(chan aisdk.DataStreamPart)
+	stream := aisdk.DataStream(func(yield func(aisdk.DataStreamPart, error) bool) {
+		for part := range source {
+			if !yield(part, nil) {
+				return
+			}
+		}
+	}).WithResumeBuffer(buf)
+
+	// The server keeps generating after the client disconnected at cursor 1.
+	done := make(chan struct{})
+	var acc aisdk.DataStreamAccumulator
+	var streamErr error
+	go func() {
+		defer close(done)
+		for _, err := range stream.WithAccumulator(&acc) {
+			if err != nil {
+				streamErr = err
+			}
+		}
+	}()
+	source <- aisdk.StartStepStreamPart{MessageID: "msg_1"}
+	source <- aisdk.TextStreamPart{Content: "Hello"}
+
+	resumed, err := buf.ResumeFrom(1)
+	require.NoError(t, err)
+
+	source <- aisdk.TextStreamPart{Content: " there!"}
+	source <- aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop}
+	close(source)
+	<-done
+	require.NoError(t, streamErr)
+	require.Equal(t, "Hello there!", acc.Messages()[0].TextContent())
+
+	var out strings.Builder
+	require.NoError(t, resumed.Pipe(&out))
+	require.Equal(t, `0:"Hello"
+2:[{"cursor":2,"type":"cursor"}]
+0:" there!"
+2:[{"cursor":3,"type":"cursor"}]
+d:{"finishReason":"stop","usage":{"promptTokens":null,"completionTokens":null}}
+2:[{"cursor":4,"type":"cursor"}]
+`, out.String())
+
+	// Only the last 3 parts are buffered.
+	_, err = buf.ResumeFrom(0)
+	require.True(t, errors.Is(err, aisdk.ErrCursorExpired))
+}
+
+func TestResumeBuffer_Disconnect(t *testing.T) {
+	t.Parallel()
+
+	buf := aisdk.NewResumeBuffer(10)
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "Hello"},
+		aisdk.TextStreamPart{Content: " there!"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	).WithResumeBuffer(buf)
+
+	// The client's connection drops after the first text, which stops the
+	// stream piped to it.
+	for part := range stream {
+		if _, ok := part.(aisdk.TextStreamPart); ok {
+			break
+		}
+	}
+
+	resumed, err := buf.ResumeFrom(0)
+	require.NoError(t, err)
+	var parts []aisdk.DataStreamPart
+	var streamErr error
+	for part, err := range resumed {
+		if err != nil {
+			streamErr = err
+			break
+		}
+		if _, ok := part.(aisdk.CursorStreamPart); !ok {
+			parts = append(parts, part)
+		}
+	}
+	require.Equal(t, []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "Hello"},
+	}, parts)
+	require.ErrorIs(t, streamErr, aisdk.ErrStreamAborted)
+}
@@ -0,0 +1,212 @@
+package aisdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// StaticDataStreamOption configures StaticDataStream.
+type StaticDataStreamOption func(*staticDataStreamConfig)
+
+type staticDataStreamConfig struct {
+	interval time.Duration
+}
+
+// WithTypingInterval makes StaticDataStream yield text word by word, waiting
+// interval between words for a natural typing effect.
+func WithTypingInterval(interval time.Duration) StaticDataStreamOption {
+	return func(c *staticDataStreamConfig) {
+		c.interval = interval
+	}
+}
+
+// StaticDataStream streams pre-computed assistant messages, e.g. a cached
+// response, with the same parts as a live generation. Messages of other roles
+// are skipped. The usage is reported when the last message finishes.
+func StaticDataStream(messages []Message, usage Usage, opts ...StaticDataStreamOption) DataStream {
+	var config staticDataStreamConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return func(yield func(DataStreamPart, error) bool) {
+		var assistantMessages []Message
+		for _, message := range messages {
+			if message.Role == "assistant" {
+				assistantMessages = append(assistantMessages, message)
+			}
+		}
+
+		for i, message := range assistantMessages {
+			var messageUsage Usage
+			if i == len(assistantMessages)-1 {
+				messageUsage = usage
+			}
+			if !yieldStaticMessage(message, messageUsage, config, yield) {
+				return
+			}
+		}
+	}
+}
+
+// yieldStaticMessage yields the parts of a single message. Every step-start part
+// starts a new step, and all but the last step are continued, so that the
+// accumulator keeps them in one message.
+func yieldStaticMessage(message Message, usage Usage, config staticDataStreamConfig, yield func(DataStreamPart, error) bool) bool {
+	parts := message.Parts
+	if len(parts) == 0 && message.Content != "" {
+		parts = []Part{{Type: PartTypeText, Text: message.Content}}
+	}
+	if len(parts) == 0 || parts[0].Type != PartTypeStepStart {
+		parts = append([]Part{{Type: PartTypeStepStart}}, parts...)
+	}
+
+	finishReason := FinishReasonStop
+	for i, part := range parts {
+		switch part.Type {
+		case PartTypeStepStart:
+			if i > 0 && !yield(FinishStepStreamPart{
+				FinishReason: FinishReasonToolCalls,
+				IsContinued:  true,
+			}, nil) {
+				return false
+			}
+			if !yield(StartStepStreamPart{MessageID: message.ID}, nil) {
+				return false
+			}
+			finishReason = FinishReasonStop
+
+		case PartTypeText:
+			if !yieldStaticText(part.Text, config, yield) {
+				return false
+			}
+
+		case PartTypeReasoning:
+			if !yield(ReasoningStreamPart{Content: part.Reasoning}, nil) {
+				return false
+			}
+
+		case PartTypeToolInvocation:
+			if part.ToolInvocation == nil {
+				return yield(nil, fmt.Errorf("message part has type tool-invocation but nil ToolInvocation field (ID: %s)", message.ID))
+			}
+			args, err := staticToolArgs(part.ToolInvocation.Args)
+			if err != nil {
+				return yield(nil, fmt.Errorf("failed to convert args of tool call %s: %w", part.ToolInvocation.ToolCallID, err))
+			}
+			if !yield(ToolCallStreamPart{
+				ToolCallID: part.ToolInvocation.ToolCallID,
+				ToolName:   part.ToolInvocation.ToolName,
+				Args:       args,
+			}, nil) {
+				return false
+			}
+			finishReason = FinishReasonToolCalls
+			if part.ToolInvocation.State == ToolInvocationStateResult {
+				if !yield(ToolResultStreamPart{
+					ToolCallID: part.ToolInvocation.ToolCallID,
+					Result:     part.ToolInvocation.Result,
+				}, nil) {
+					return false
+				}
+			}
+
+		case PartTypeSource:
+			if part.Source == nil {
+				continue
+			}
+			source := SourceStreamPart{SourceType: "url", URL: part.Source.URI}
+			if id, ok := part.Source.Metadata["id"].(string); ok {
+				source.ID = id
+			}
+			if title, ok := part.Source.Metadata["title"].(string); ok {
+				source.Title = title
+			}
+			if sourceType, ok := part.Source.Metadata["sourceType"].(string); ok && sourceType != "" {
+				source.SourceType = sourceType
+			}
+			if !yield(source, nil) {
+				return false
+			}
+
+		case PartTypeFile:
+			if !yield(FileStreamPart{Data: part.Data, MimeType: part.MimeType}, nil) {
+				return false
+			}
+		}
+	}
+
+	if len(message.Annotations) > 0 {
+		if !yield(MessageAnnotationStreamPart{Content: message.Annotations}, nil) {
+			return false
+		}
+	}
+
+	if !yield(FinishStepStreamPart{
+		FinishReason: finishReason,
+		Usage:        usage,
+	}, nil) {
+		return false
+	}
+	return yield(FinishMessageStreamPart{
+		FinishReason: finishReason,
+		Usage:        usage,
+	}, nil)
+}
+
+// yieldStaticText yields text at once, or word by word with a typing interval.
+func yieldStaticText(text string, config staticDataStreamConfig, yield func(DataStreamPart, error) bool) bool {
+	if config.interval <= 0 {
+		return text == "" || yield(TextStreamPart{Content: text}, nil)
+	}
+
+	// Every word is yielded with its trailing whitespace.
+	for len(text) > 0 {
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			end = len(text)
+		}
+		if next := strings.IndexFunc(text[end:], func(r rune) bool { return !unicode.IsSpace(r) }); next < 0 {
+			end = len(text)
+		} else {
+			end += next
+		}
+		if !yield(TextStreamPart{Content: text[:end]}, nil) {
+			return false
+		}
+		text = text[end:]
+		if len(text) > 0 {
+			time.Sleep(config.interval)
+		}
+	}
+	return true
+}
+
+// staticToolArgs returns the args of a tool invocation as a map, converting
+// args that were decoded or accumulated as another type.
+func staticToolArgs(args any) (map[string]any, error) {
+	switch args := args.(type) {
+	case nil:
+		return map[string]any{}, nil
+	case map[string]any:
+		return args, nil
+	case string:
+		parsed := map[string]any{}
+		if strings.TrimSpace(args) == "" {
+			return parsed, nil
+		}
+		err := json.Unmarshal([]byte(args), &parsed)
+		return parsed, err
+	default:
+		data, err := json.Marshal(args)
+		if err != nil {
+			return nil, err
+		}
+		parsed := map[string]any{}
+		err = json.Unmarshal(data, &parsed)
+		return parsed, err
+	}
+}
@@ -0,0 +1,58 @@
+package aisdk_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticDataStream(t *testing.T) {
+	t.Parallel()
+
+	// Accumulate a live multi-step generation to cache it.
+	var live aisdk.DataStreamAccumulator
+	for _, err := range partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "Let me check."},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "now", Args: map[string]any{}},
+		aisdk.ToolResultStreamPart{ToolCallID: "tool_1", Result: "noon"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls, IsContinued: true},
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "It's noon."},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	).WithAccumulator(&live) {
+		require.NoError(t, err)
+	}
+	require.Len(t, live.Messages(), 1)
+
+	usage := aisdk.Usage{PromptTokens: int64Ptr(10), CompletionTokens: int64Ptr(5)}
+	messages := append([]aisdk.Message{{Role: "user", Content: "What time is it?"}}, live.Messages()...)
+
+	var replayed aisdk.DataStreamAccumulator
+	for _, err := range aisdk.StaticDataStream(messages, usage).WithAccumulator(&replayed) {
+		require.NoError(t, err)
+	}
+	require.Equal(t, live.Messages(), replayed.Messages())
+	require.Equal(t, usage, replayed.Usage())
+}
+
+func TestStaticDataStream_TypingInterval(t *testing.T) {
+	t.Parallel()
+
+	stream := aisdk.StaticDataStream([]aisdk.Message{{
+		Role:  "assistant",
+		Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Hello  there, world!"}},
+	}}, aisdk.Usage{}, aisdk.WithTypingInterval(time.Millisecond))
+
+	var text []string
+	for part, err := range stream {
+		require.NoError(t, err)
+		if p, ok := part.(aisdk.TextStreamPart); ok {
+			text = append(text, p.Content)
+		}
+	}
+	require.Equal(t, []string{"Hello  ", "there, ", "world!"}, text)
+}
@@ -1,11 +1,21 @@
 package aisdk
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"iter"
+	"log/slog"
+	"math"
 	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Chat is the structure sent from `useChat` to the server.
@@ -16,10 +26,136 @@ type Chat struct {
 }
 
 // DataStream is a stream of DataStreamParts.
+//
+// Transport errors, like a failed request or a broken connection, are yielded
+// as the error value and end the stream. Provider adapters wrap them in a
+// *ProviderError. ErrorStreamParts are reserved for errors that are part of the
+// response itself and should be rendered by the client.
 type DataStream iter.Seq2[DataStreamPart, error]
 
+// ProviderError is a transport error of a provider's stream.
+type ProviderError struct {
+	// Provider is the name of the provider, e.g. "openai" or "anthropic".
+	Provider string
+	Err      error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s stream error: %v", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimitError is a rate limit (HTTP 429) response of a provider. It is
+// wrapped in a *ProviderError, so detect it with errors.As.
+type RateLimitError struct {
+	// RetryAfter is how long the provider asked to wait before retrying,
+	// or zero if it didn't say.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited, retry after %s: %v", e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("rate limited: %v", e.Err)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// rateLimitError returns err as a *RateLimitError if the provider responded
+// with HTTP 429, and err unchanged otherwise.
+func rateLimitError(statusCode int, header http.Header, err error) error {
+	if statusCode != http.StatusTooManyRequests {
+		return err
+	}
+	return &RateLimitError{RetryAfter: parseRetryAfter(header), Err: err}
+}
+
+// parseRetryAfter parses the retry-after-ms header sent by OpenAI and Anthropic,
+// falling back to the standard Retry-After header in seconds or as a date.
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	retryAfter := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(retryAfter, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// ErrorDataStream returns a DataStream with a single ErrorStreamPart for err.
+// Use it to stream an error that occurred while setting up a provider stream,
+// so the client renders it like any other stream error instead of receiving
+// an HTTP error response.
+func ErrorDataStream(err error) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		yield(ErrorStreamPart{Content: err.Error()}, nil)
+	}
+}
+
+// ToolCallingOption configures WithToolCalling.
+type ToolCallingOption func(*toolCallingConfig)
+
+type toolCallingConfig struct {
+	status    bool
+	useNumber bool
+}
+
+// WithToolCallStatus makes WithToolCalling emit a DataStreamDataPart when a
+// tool starts running and another with its duration when it completes, so
+// front-ends can show progress without timing tools themselves:
+//
+//	{"type":"tool-status","toolCallId":"...","status":"running"}
+//	{"type":"tool-status","toolCallId":"...","status":"completed","durationMs":1200}
+func WithToolCallStatus() ToolCallingOption {
+	return func(c *toolCallingConfig) {
+		c.status = true
+	}
+}
+
+// WithToolCallNumbers makes WithToolCalling decode numbers in streamed tool
+// call args as json.Number instead of float64, so that integers like IDs reach
+// the handler, and are sent back to the provider, unchanged.
+func WithToolCallNumbers() ToolCallingOption {
+	return func(c *toolCallingConfig) {
+		c.useNumber = true
+	}
+}
+
 // WithToolCalling passes tool calls to the handleToolCall function.
-func (s DataStream) WithToolCalling(handleToolCall func(toolCall ToolCall) any) DataStream {
+// If the handler returns an error, its message is sent as the result and the
+// result is marked with IsError.
+func (s DataStream) WithToolCalling(handleToolCall func(toolCall ToolCall) any, opts ...ToolCallingOption) DataStream {
+	return s.WithToolCallingProgress(func(toolCall ToolCall, _ func(status string)) any {
+		return handleToolCall(toolCall)
+	}, opts...)
+}
+
+// WithToolCallingProgress is like WithToolCalling, but the handler can report
+// the phases of a long-running tool, like "Authenticating" or "Fetching", by
+// calling progress. Each call yields a MessageAnnotationStreamPart, so the UI
+// can list the phases under the tool invocation:
+//
+//	{"type":"tool-progress","toolCallId":"...","status":"Fetching"}
+//
+// progress must be called before the handler returns, from its goroutine.
+// Later calls are ignored.
+func (s DataStream) WithToolCallingProgress(handleToolCall func(toolCall ToolCall, progress func(status string)) any, opts ...ToolCallingOption) DataStream {
+	var config toolCallingConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
 	return func(yield func(DataStreamPart, error) bool) {
 		// Track partial tool calls by ID
 		partialToolCalls := make(map[string]struct {
@@ -27,34 +163,88 @@ func (s DataStream) WithToolCalling(handleToolCall func(toolCall ToolCall) any)
 			step     int
 			toolName string
 		})
+		// Track the IDs of tool calls by index, for deltas without an ID
+		indexedIDs := make(toolCallIDs)
 
 		// Track current step
 		step := 0
 
-		// Process a complete tool call
-		processToolCall := func(id string, name string, args map[string]any) bool {
-			if !yield(ToolCallStreamPart{
-				ToolCallID: id,
-				ToolName:   name,
-				Args:       args,
-			}, nil) {
-				return false
+		// Track handled tool calls, so that a ToolCallStreamPart sent by the
+		// provider after its deltas doesn't call the handler twice.
+		handled := make(map[string]bool)
+
+		// Call the handler and yield the result
+		handle := func(id string, name string, args map[string]any) bool {
+			handled[id] = true
+			start := time.Now()
+			if config.status {
+				if !yield(DataStreamDataPart{Content: []any{map[string]any{
+					"type":       "tool-status",
+					"toolCallId": id,
+					"status":     "running",
+				}}}, nil) {
+					return false
+				}
 			}
 
-			// Call the handler and get the result
+			running, stopped := true, false
 			result := handleToolCall(ToolCall{
 				ID:   id,
 				Name: name,
 				Args: args,
+			}, func(status string) {
+				if !running || stopped {
+					return
+				}
+				stopped = !yield(MessageAnnotationStreamPart{Content: []any{map[string]any{
+					"type":       "tool-progress",
+					"toolCallId": id,
+					"status":     status,
+				}}}, nil)
 			})
+			running = false
+			if stopped {
+				return false
+			}
+
+			if config.status {
+				if !yield(DataStreamDataPart{Content: []any{map[string]any{
+					"type":       "tool-status",
+					"toolCallId": id,
+					"status":     "completed",
+					"durationMs": time.Since(start).Milliseconds(),
+				}}}, nil) {
+					return false
+				}
+			}
+
+			// An error is sent as its message, since it would marshal to {}.
+			if err, ok := result.(error); ok {
+				return yield(ToolResultStreamPart{
+					ToolCallID: id,
+					Result:     err.Error(),
+					IsError:    true,
+				}, nil)
+			}
 
-			// Yield the result
 			return yield(ToolResultStreamPart{
 				ToolCallID: id,
 				Result:     result,
 			}, nil)
 		}
 
+		// Process a complete tool call
+		processToolCall := func(id string, name string, args map[string]any) bool {
+			if !yield(ToolCallStreamPart{
+				ToolCallID: id,
+				ToolName:   name,
+				Args:       args,
+			}, nil) {
+				return false
+			}
+			return handle(id, name, args)
+		}
+
 		// Process a tool call delta
 		processDelta := func(id string, delta string) bool {
 			partialCall := partialToolCalls[id]
@@ -62,8 +252,7 @@ func (s DataStream) WithToolCalling(handleToolCall func(toolCall ToolCall) any)
 			partialToolCalls[id] = partialCall
 
 			// Try to parse the partial JSON
-			var args map[string]any
-			if err := json.Unmarshal([]byte(partialCall.text), &args); err == nil {
+			if args, err := decodeToolArgs(partialCall.text, config.useNumber); err == nil {
 				// Successfully parsed complete args, process the call
 				if !processToolCall(id, partialCall.toolName, args) {
 					return false
@@ -80,6 +269,15 @@ func (s DataStream) WithToolCalling(handleToolCall func(toolCall ToolCall) any)
 				return
 			}
 
+			if p, ok := part.(ToolCallStreamPart); ok && handled[p.ToolCallID] {
+				// The call was already yielded when its deltas completed.
+				continue
+			}
+
+			if p, ok := part.(ToolCallDeltaStreamPart); ok {
+				part = indexedIDs.resolve(p)
+			}
+
 			if !yield(part, nil) {
 				return
 			}
@@ -90,6 +288,7 @@ func (s DataStream) WithToolCalling(handleToolCall func(toolCall ToolCall) any)
 				step++
 
 			case ToolCallStartStreamPart:
+				indexedIDs[p.Index] = p.ToolCallID
 				// Initialize a new partial tool call
 				partialToolCalls[p.ToolCallID] = struct {
 					text     string
@@ -101,65 +300,841 @@ func (s DataStream) WithToolCalling(handleToolCall func(toolCall ToolCall) any)
 					toolName: p.ToolName,
 				}
 
-			case ToolCallDeltaStreamPart:
-				if !processDelta(p.ToolCallID, p.ArgsTextDelta) {
-					return
-				}
+			case ToolCallDeltaStreamPart:
+				if !processDelta(p.ToolCallID, p.ArgsTextDelta) {
+					return
+				}
+
+			case ToolCallStreamPart:
+				// The call was already yielded above.
+				if !handle(p.ToolCallID, p.ToolName, p.Args) {
+					return
+				}
+				delete(partialToolCalls, p.ToolCallID)
+
+			case FinishStepStreamPart:
+				// Clean up any remaining partial tool calls
+				for id := range partialToolCalls {
+					delete(partialToolCalls, id)
+				}
+			}
+		}
+	}
+}
+
+// WithAccumulator passes parts to the accumulator which aggregates them into a single message.
+func (s DataStream) WithAccumulator(accumulator *DataStreamAccumulator) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		for part, err := range s {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			err = accumulator.Push(part)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			yield(part, nil)
+		}
+	}
+}
+
+// WithBatchedToolCalls coalesces tool call deltas into a single ToolCallStreamPart
+// that is emitted once the arguments are complete. ToolCallStartStreamPart and
+// ToolCallDeltaStreamPart parts are not passed through, so consumers receive
+// one '9' part per tool call instead of a 'b'/'c' sequence.
+func (s DataStream) WithBatchedToolCalls() DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		type accumulatingToolCall struct {
+			toolName string
+			text     string
+		}
+		// Pending tool calls are kept in order so they are flushed deterministically.
+		var pendingIDs []string
+		pending := make(map[string]*accumulatingToolCall)
+		indexedIDs := make(toolCallIDs)
+		// Emitted tool calls are tracked to drop a ToolCallStreamPart that the
+		// provider sends once the call is complete.
+		emitted := make(map[string]bool)
+
+		emit := func(id string, args map[string]any) bool {
+			call := pending[id]
+			delete(pending, id)
+			emitted[id] = true
+			for i, pendingID := range pendingIDs {
+				if pendingID == id {
+					pendingIDs = append(pendingIDs[:i], pendingIDs[i+1:]...)
+					break
+				}
+			}
+			return yield(ToolCallStreamPart{
+				ToolCallID: id,
+				ToolName:   call.toolName,
+				Args:       args,
+			}, nil)
+		}
+
+		// Flush the tool calls whose arguments never parsed, e.g. tools
+		// without arguments whose deltas are empty.
+		flush := func() bool {
+			for len(pendingIDs) > 0 {
+				id := pendingIDs[0]
+				args := map[string]any{}
+				if text := pending[id].text; strings.TrimSpace(text) != "" {
+					if err := json.Unmarshal([]byte(text), &args); err != nil {
+						yield(nil, fmt.Errorf("failed to parse arguments for tool call %s: %w", id, err))
+						return false
+					}
+				}
+				if !emit(id, args) {
+					return false
+				}
+			}
+			return true
+		}
+
+		for part, err := range s {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			switch p := part.(type) {
+			case ToolCallStartStreamPart:
+				if _, ok := pending[p.ToolCallID]; !ok {
+					pendingIDs = append(pendingIDs, p.ToolCallID)
+				}
+				pending[p.ToolCallID] = &accumulatingToolCall{toolName: p.ToolName}
+				indexedIDs[p.Index] = p.ToolCallID
+				continue
+
+			case ToolCallDeltaStreamPart:
+				p = indexedIDs.resolve(p)
+				call, ok := pending[p.ToolCallID]
+				if !ok {
+					yield(nil, fmt.Errorf("received tool call delta for unknown tool call %s", p.ToolCallID))
+					return
+				}
+				call.text += p.ArgsTextDelta
+
+				var args map[string]any
+				if err := json.Unmarshal([]byte(call.text), &args); err == nil {
+					if !emit(p.ToolCallID, args) {
+						return
+					}
+				}
+				continue
+
+			case ToolCallStreamPart:
+				if emitted[p.ToolCallID] {
+					continue
+				}
+				if _, ok := pending[p.ToolCallID]; ok {
+					if !emit(p.ToolCallID, p.Args) {
+						return
+					}
+					continue
+				}
+
+			case FinishStepStreamPart, FinishMessageStreamPart:
+				if !flush() {
+					return
+				}
+			}
+
+			if !yield(part, nil) {
+				return
+			}
+		}
+
+		flush()
+	}
+}
+
+// WithPartialJSON parses the text of a message as partial JSON on each text delta
+// and calls onUpdate with the best-effort object whenever it changes. Use it to
+// render structured outputs (e.g. `response_format: json_schema`) progressively.
+func (s DataStream) WithPartialJSON(onUpdate func(partial map[string]any)) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		var text string
+		var last map[string]any
+
+		for part, err := range s {
+			if err == nil {
+				switch p := part.(type) {
+				case TextStreamPart:
+					text += p.Content
+					if partial, ok := parsePartialJSON(text, false); ok && !reflect.DeepEqual(partial, last) {
+						last = partial
+						onUpdate(partial)
+					}
+				case FinishMessageStreamPart:
+					text = ""
+					last = nil
+				}
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// Filter passes through only the parts for which keep returns true. Errors are
+// always passed through. Dropping structural parts like StartStepStreamPart,
+// FinishStepStreamPart or FinishMessageStreamPart breaks the accumulator and
+// the client.
+func (s DataStream) Filter(keep func(part DataStreamPart) bool) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		for part, err := range s {
+			if err == nil && !keep(part) {
+				continue
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// Map replaces every part with the part returned by fn, e.g. to rename tools or
+// rewrite source URLs. Errors are passed through unchanged. Parts mapped to nil
+// are dropped, with the same caveats as Filter.
+func (s DataStream) Map(fn func(part DataStreamPart) DataStreamPart) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		for part, err := range s {
+			if err == nil {
+				part = fn(part)
+				if part == nil {
+					continue
+				}
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// WithoutReasoning drops reasoning, redacted reasoning and reasoning signature
+// parts, so the client never sees the model's reasoning. Observers placed
+// before it, like WithAccumulator or OnReasoning, still receive them:
+//
+//	stream.OnReasoning(logReasoning).WithoutReasoning().Pipe(w)
+func (s DataStream) WithoutReasoning() DataStream {
+	return s.Filter(func(part DataStreamPart) bool {
+		switch part.(type) {
+		case ReasoningStreamPart, RedactedReasoningStreamPart, ReasoningSignatureStreamPart:
+			return false
+		}
+		return true
+	})
+}
+
+// charsPerToken is the average number of characters per token, used to
+// estimate token counts without a tokenizer. Claude's tokenizer produces
+// slightly more tokens for the same text than OpenAI's.
+func charsPerToken(model string) float64 {
+	if strings.HasPrefix(model, "claude") {
+		return 3.5
+	}
+	return 4
+}
+
+// generatedChars returns the number of characters the model generated for
+// part, counting text, reasoning and tool call argument deltas.
+func generatedChars(part DataStreamPart) int {
+	switch p := part.(type) {
+	case TextStreamPart:
+		return utf8.RuneCountInString(p.Content)
+	case ReasoningStreamPart:
+		return utf8.RuneCountInString(p.Content)
+	case ToolCallDeltaStreamPart:
+		return utf8.RuneCountInString(p.ArgsTextDelta)
+	}
+	return 0
+}
+
+// WithTokenCounter calls onUpdate with a running estimate of the completion
+// tokens whenever it grows, counting text, reasoning and tool call argument
+// deltas. It's for live counters and caps on providers that report usage late
+// or not at all; stop iterating, or cancel the request, once a cap is reached.
+//
+// The estimate is based on the characters streamed so far and the model's
+// average characters per token, so it can be off by a few percent. Prefer the
+// usage of the FinishMessageStreamPart once it arrives.
+func (s DataStream) WithTokenCounter(model string, onUpdate func(completionTokens int)) DataStream {
+	ratio := charsPerToken(model)
+	return func(yield func(DataStreamPart, error) bool) {
+		chars := 0
+		tokens := 0
+		for part, err := range s {
+			if err == nil {
+				chars += generatedChars(part)
+				if estimate := int(math.Ceil(float64(chars) / ratio)); estimate > tokens {
+					tokens = estimate
+					onUpdate(tokens)
+				}
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// WithMaxTokens ends the stream with FinishReasonLength once the estimated
+// completion tokens exceed n, as a guard for providers whose max tokens can't
+// be set or trusted. Tokens are estimated like in WithTokenCounter. Text and
+// reasoning are cut at the limit, while a tool call delta that crosses it is
+// dropped, since partial arguments aren't valid JSON. The stream isn't pulled
+// any further.
+func (s DataStream) WithMaxTokens(n int, model string) DataStream {
+	maxChars := int(float64(n) * charsPerToken(model))
+	return func(yield func(DataStreamPart, error) bool) {
+		chars := 0
+		for part, err := range s {
+			if err == nil {
+				generated := generatedChars(part)
+				if chars+generated > maxChars {
+					remaining := maxChars - chars
+					switch p := part.(type) {
+					case TextStreamPart:
+						if remaining > 0 && !yield(TextStreamPart{Content: string([]rune(p.Content)[:remaining])}, nil) {
+							return
+						}
+					case ReasoningStreamPart:
+						if remaining > 0 && !yield(ReasoningStreamPart{Content: string([]rune(p.Content)[:remaining])}, nil) {
+							return
+						}
+					}
+					if !yield(FinishStepStreamPart{FinishReason: FinishReasonLength}, nil) {
+						return
+					}
+					yield(FinishMessageStreamPart{FinishReason: FinishReasonLength}, nil)
+					return
+				}
+				chars += generated
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// StopOn ends the stream when its text contains sequence, like a provider's
+// stop sequences but for providers or requests without them. The sequence and
+// any text after it are dropped, the current step and message are finished
+// with FinishReasonStop, and the stream isn't pulled any further. Text that
+// could be the start of the sequence is held back until the next part shows
+// whether it is.
+func (s DataStream) StopOn(sequence string) DataStream {
+	if sequence == "" {
+		return s
+	}
+	return func(yield func(DataStreamPart, error) bool) {
+		pending := ""
+		for part, err := range s {
+			text, ok := part.(TextStreamPart)
+			if err != nil || !ok {
+				if pending != "" {
+					if !yield(TextStreamPart{Content: pending}, nil) {
+						return
+					}
+					pending = ""
+				}
+				if !yield(part, err) {
+					return
+				}
+				continue
+			}
+
+			pending += text.Content
+			if i := strings.Index(pending, sequence); i >= 0 {
+				if i > 0 && !yield(TextStreamPart{Content: pending[:i]}, nil) {
+					return
+				}
+				if !yield(FinishStepStreamPart{FinishReason: FinishReasonStop}, nil) {
+					return
+				}
+				yield(FinishMessageStreamPart{FinishReason: FinishReasonStop}, nil)
+				return
+			}
+			// Hold back the longest suffix that is a prefix of the sequence.
+			held := 0
+			for n := min(len(pending), len(sequence)-1); n > 0; n-- {
+				if strings.HasSuffix(pending, sequence[:n]) {
+					held = n
+					break
+				}
+			}
+			if flush := pending[:len(pending)-held]; flush != "" {
+				if !yield(TextStreamPart{Content: flush}, nil) {
+					return
+				}
+			}
+			pending = pending[len(pending)-held:]
+		}
+		if pending != "" {
+			yield(TextStreamPart{Content: pending}, nil)
+		}
+	}
+}
+
+// MergeStepStarts drops a StartStepStreamPart that directly follows another,
+// since a step without content isn't a step. Parts that don't render
+// anything, like an empty DataStreamDataPart from WithHeartbeat, don't count
+// as content.
+func (s DataStream) MergeStepStarts() DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		stepStarted := false
+		for part, err := range s {
+			if err == nil {
+				switch p := part.(type) {
+				case StartStepStreamPart:
+					if stepStarted {
+						continue
+					}
+					stepStarted = true
+				case DataStreamDataPart:
+					if len(p.Content) > 0 {
+						stepStarted = false
+					}
+				default:
+					stepStarted = false
+				}
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// streamItem is a part or error of a stream iterated by pumpStream.
+type streamItem struct {
+	part DataStreamPart
+	err  error
+}
+
+// pumpStream iterates s in its own goroutine and sends its parts to the
+// returned channel, which is closed once s ends. Closing done stops the
+// goroutine once s yields its next part.
+func pumpStream(s DataStream, done <-chan struct{}) <-chan streamItem {
+	items := make(chan streamItem)
+	go func() {
+		defer close(items)
+		for part, err := range s {
+			select {
+			case items <- streamItem{part: part, err: err}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return items
+}
+
+// WithHeartbeat yields an empty DataStreamDataPart whenever no part was yielded
+// for interval, e.g. while a slow tool runs, so that proxies and load balancers
+// don't close an idle connection. The stream is iterated in its own goroutine,
+// so tool handlers run concurrently with the consumer. If the consumer stops
+// early, the goroutine exits once the stream yields its next part.
+func (s DataStream) WithHeartbeat(interval time.Duration) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		done := make(chan struct{})
+		defer close(done)
+		items := pumpStream(s, done)
+
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		for {
+			select {
+			case it, ok := <-items:
+				if !ok {
+					return
+				}
+				if !yield(it.part, it.err) {
+					return
+				}
+			case <-timer.C:
+				if !yield(DataStreamDataPart{Content: []any{}}, nil) {
+					return
+				}
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+// WithTimeout fails the stream with an error wrapping context.DeadlineExceeded
+// if it doesn't complete within d, e.g. when a provider stalls mid-response.
+// Like WithHeartbeat, the stream is iterated in its own goroutine, which exits
+// once the stalled stream yields its next part. Cancel the request's context as
+// well to release the connection right away.
+func (s DataStream) WithTimeout(d time.Duration) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		done := make(chan struct{})
+		defer close(done)
+		items := pumpStream(s, done)
+
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		for {
+			select {
+			case it, ok := <-items:
+				if !ok {
+					return
+				}
+				if !yield(it.part, it.err) {
+					return
+				}
+			case <-timer.C:
+				yield(nil, fmt.Errorf("stream did not complete within %s: %w", d, context.DeadlineExceeded))
+				return
+			}
+		}
+	}
+}
+
+// WithRateLimit paces the stream to at most partsPerSecond parts, e.g. for
+// demos or clients that can't keep up with a fast model. It uses a token
+// bucket holding a single token, so parts are spread evenly instead of sent in
+// bursts. Finish parts, error parts and errors bypass the limiter, so the
+// stream always ends promptly. A partsPerSecond of zero or less disables it.
+func (s DataStream) WithRateLimit(partsPerSecond float64) DataStream {
+	if partsPerSecond <= 0 {
+		return s
+	}
+	interval := time.Duration(float64(time.Second) / partsPerSecond)
+	return func(yield func(DataStreamPart, error) bool) {
+		tokens := 1.0
+		last := time.Now()
+		for part, err := range s {
+			bypass := err != nil
+			switch part.(type) {
+			case FinishStepStreamPart, FinishMessageStreamPart, ErrorStreamPart:
+				bypass = true
+			}
+			if !bypass {
+				now := time.Now()
+				tokens = min(tokens+now.Sub(last).Seconds()*partsPerSecond, 1)
+				last = now
+				if tokens < 1 {
+					// Wait for the token to refill, and spend it right away.
+					time.Sleep(time.Duration((1 - tokens) * float64(interval)))
+					last = time.Now()
+					tokens = 0
+				} else {
+					tokens--
+				}
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// OnText calls onText with the content of every TextStreamPart as it passes through.
+func (s DataStream) OnText(onText func(delta string)) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		for part, err := range s {
+			if err == nil {
+				if p, ok := part.(TextStreamPart); ok {
+					onText(p.Content)
+				}
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// OnReasoning calls onReasoning with the content of every ReasoningStreamPart as it passes through.
+func (s DataStream) OnReasoning(onReasoning func(delta string)) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		for part, err := range s {
+			if err == nil {
+				if p, ok := part.(ReasoningStreamPart); ok {
+					onReasoning(p.Content)
+				}
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// WithUsageCallback calls onUsage once with the final usage and finish reason
+// when the stream's FinishMessageStreamPart passes through, without the cost of
+// a full accumulator.
+func (s DataStream) WithUsageCallback(onUsage func(usage Usage, finishReason FinishReason)) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		called := false
+		for part, err := range s {
+			if err == nil && !called {
+				if p, ok := part.(FinishMessageStreamPart); ok {
+					called = true
+					onUsage(p.Usage, p.FinishReason)
+				}
+			}
+			if !yield(part, err) {
+				return
+			}
+		}
+	}
+}
+
+// WithFinish calls onFinish once with the accumulated messages, finish reason
+// and usage when the stream ends, while the parts still stream to the consumer.
+// It isn't called if the stream fails or the consumer stops early.
+func (s DataStream) WithFinish(onFinish func(messages []Message, finishReason FinishReason, usage Usage)) DataStream {
+	return func(yield func(DataStreamPart, error) bool) {
+		var accumulator DataStreamAccumulator
+		for part, err := range s {
+			if err == nil {
+				err = accumulator.Push(part)
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(part, nil) {
+				return
+			}
+		}
+		onFinish(accumulator.Messages(), accumulator.FinishReason(), accumulator.Usage())
+	}
+}
+
+// Prefill returns the text of a trailing assistant message, which providers
+// like Anthropic continue instead of starting a new response, or an empty
+// string if the last message isn't from the assistant.
+func Prefill(messages []Message) string {
+	if len(messages) == 0 || messages[len(messages)-1].Role != "assistant" {
+		return ""
+	}
+	return messages[len(messages)-1].TextContent()
+}
 
-			case ToolCallStreamPart:
-				if !processToolCall(p.ToolCallID, p.ToolName, p.Args) {
+// WithPrefill yields prefill as text at the start of the first step, so that
+// the client and the accumulator see the complete response of a prefilled
+// request, not just its continuation. Trailing whitespace is trimmed, as
+// MessagesToAnthropic does for the request:
+//
+//	stream = aisdk.AnthropicToDataStream(s).WithPrefill(aisdk.Prefill(messages))
+func (s DataStream) WithPrefill(prefill string) DataStream {
+	prefill = strings.TrimRightFunc(prefill, unicode.IsSpace)
+	if prefill == "" {
+		return s
+	}
+	return func(yield func(DataStreamPart, error) bool) {
+		started := false
+		for part, err := range s {
+			if !yield(part, err) {
+				return
+			}
+			if _, ok := part.(StartStepStreamPart); ok && !started {
+				started = true
+				if !yield(TextStreamPart{Content: prefill}, nil) {
 					return
 				}
-				delete(partialToolCalls, p.ToolCallID)
+			}
+		}
+	}
+}
 
-			case FinishStepStreamPart:
-				// Clean up any remaining partial tool calls
-				for id := range partialToolCalls {
-					delete(partialToolCalls, id)
-				}
+// WithLogger logs every part that passes through: tool calls and results at
+// info level, errors at error level, the finish reason and token totals at
+// info level when the stream finishes, and all other parts at debug level.
+// Attach request-scoped attributes to the logger, e.g. with
+// logger.With("requestId", id). A nil logger returns the stream unchanged.
+func (s DataStream) WithLogger(logger *slog.Logger) DataStream {
+	if logger == nil {
+		return s
+	}
+	return func(yield func(DataStreamPart, error) bool) {
+		for part, err := range s {
+			switch p := part.(type) {
+			case nil:
+				logger.Error("data stream error", "error", err)
+			case ToolCallStreamPart:
+				logger.Info("tool call", "toolCallId", p.ToolCallID, "toolName", p.ToolName)
+			case ToolResultStreamPart:
+				logger.Info("tool result", "toolCallId", p.ToolCallID, "isError", p.IsError)
+			case ErrorStreamPart:
+				logger.Error("data stream error part", "error", p.Content)
+			case FinishMessageStreamPart:
+				logger.Info("data stream finished",
+					"finishReason", p.FinishReason,
+					"promptTokens", p.Usage.PromptTokensOrZero(),
+					"completionTokens", p.Usage.CompletionTokensOrZero(),
+				)
+			default:
+				logger.Debug("data stream part", "type", fmt.Sprintf("%T", p))
+			}
+			if !yield(part, err) {
+				return
 			}
 		}
 	}
 }
 
-// WithAccumulator passes parts to the accumulator which aggregates them into a single message.
-func (s DataStream) WithAccumulator(accumulator *DataStreamAccumulator) DataStream {
+// Record writes every part to the writer in the wire format as it passes through,
+// so a session can be saved and replayed later. Unlike Pipe, tool call deltas
+// and tool calls are written too.
+func (s DataStream) Record(w io.Writer) DataStream {
 	return func(yield func(DataStreamPart, error) bool) {
 		for part, err := range s {
 			if err != nil {
 				yield(nil, err)
 				return
 			}
-			err = accumulator.Push(part)
+			formatted, err := part.Format()
 			if err != nil {
 				yield(nil, err)
 				return
 			}
-			yield(part, nil)
+			_, err = io.WriteString(w, formatted)
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to record part: %w", err))
+				return
+			}
+			if !yield(part, nil) {
+				return
+			}
+		}
+	}
+}
+
+// DataStreamStage is a stage of a Pipeline, created with MiddlewareStage,
+// ToolCallingStage or AccumulatorStage.
+type DataStreamStage struct {
+	order int
+	apply func(DataStream) DataStream
+}
+
+// Stages run in the order of their kind.
+const (
+	middlewareStageOrder = iota
+	toolCallingStageOrder
+	accumulatorStageOrder
+)
+
+// MiddlewareStage transforms the provider's stream before tool calls are
+// handled, e.g. with Filter, Map or WithPrefill.
+func MiddlewareStage(middleware func(DataStream) DataStream) DataStreamStage {
+	return DataStreamStage{order: middlewareStageOrder, apply: middleware}
+}
+
+// ToolCallingStage handles tool calls like WithToolCalling.
+func ToolCallingStage(handleToolCall func(toolCall ToolCall) any, opts ...ToolCallingOption) DataStreamStage {
+	return DataStreamStage{order: toolCallingStageOrder, apply: func(s DataStream) DataStream {
+		return s.WithToolCalling(handleToolCall, opts...)
+	}}
+}
+
+// AccumulatorStage accumulates the stream like WithAccumulator.
+func AccumulatorStage(accumulator *DataStreamAccumulator) DataStreamStage {
+	return DataStreamStage{order: accumulatorStageOrder, apply: func(s DataStream) DataStream {
+		return s.WithAccumulator(accumulator)
+	}}
+}
+
+// Pipeline applies the stages in the order middleware, tool calling,
+// accumulator, whatever order they are passed in, so that the accumulator
+// sees the tool results and the transformed stream. Stages of the same kind
+// keep their order:
+//
+//	stream = stream.Pipeline(
+//		aisdk.AccumulatorStage(&acc),
+//		aisdk.ToolCallingStage(handleToolCall),
+//		aisdk.MiddlewareStage(aisdk.DataStream.MergeStepStarts),
+//	)
+func (s DataStream) Pipeline(stages ...DataStreamStage) DataStream {
+	stages = slices.Clone(stages)
+	slices.SortStableFunc(stages, func(a, b DataStreamStage) int {
+		return a.order - b.order
+	})
+	for _, stage := range stages {
+		s = stage.apply(s)
+	}
+	return s
+}
+
+// Drain consumes the stream without writing it anywhere, e.g. to run it for
+// the side effects of an accumulator or observer, and returns the first error.
+func (s DataStream) Drain() error {
+	for _, err := range s {
+		if err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// PipeOption configures Pipe.
+type PipeOption func(*pipeConfig)
+
+type pipeConfig struct {
+	rawHTML bool
+}
+
+// WithoutHTMLEscaping writes <, > and & as is, instead of escaped as \u003c,
+// \u003e and \u0026 like json.Marshal does, for clients that display the raw
+// stream, e.g. in a terminal. Either way the wire format is valid JSON that
+// decodes to the same text.
+func WithoutHTMLEscaping() PipeOption {
+	return func(c *pipeConfig) {
+		c.rawHTML = true
+	}
 }
 
 // Pipe iterates over the DataStream and writes the parts to the writer.
-func (s DataStream) Pipe(w io.Writer) error {
+func (s DataStream) Pipe(w io.Writer, opts ...PipeOption) error {
+	var config pipeConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		flusher = nil
 	}
 
 	var pipeErr error
+	startedToolCalls := make(map[string]struct{})
 	s(func(part DataStreamPart, err error) bool {
 		if err != nil {
 			pipeErr = err
 			return false
 		}
 
-		// Skip streaming 'c' (ToolCallDeltaStreamPart) and '9' (ToolCallStreamPart) messages
-		if part.TypeID() == 'c' || part.TypeID() == '9' {
+		switch p := part.(type) {
+		case ToolCallStartStreamPart:
+			startedToolCalls[p.ToolCallID] = struct{}{}
+		case ToolCallDeltaStreamPart:
+			// Skip streaming 'c' (ToolCallDeltaStreamPart) messages
 			return true
+		case ToolCallStreamPart:
+			// Skip streaming '9' (ToolCallStreamPart) messages for tool calls
+			// the client already knows from their 'b' part. Batched tool
+			// calls have no 'b' part, so they are streamed.
+			if _, ok := startedToolCalls[p.ToolCallID]; ok {
+				return true
+			}
 		}
 
-		formatted, err := part.Format()
+		var formatted string
+		if p, ok := part.(jsonPart); ok && config.rawHTML {
+			formatted, err = formatJSONPart(p, false)
+		} else {
+			formatted, err = part.Format()
+		}
 		if err != nil {
 			pipeErr = err
 			return false
@@ -190,12 +1165,9 @@ type TextStreamPart struct {
 
 func (p TextStreamPart) TypeID() byte { return '0' }
 func (p TextStreamPart) Format() (string, error) {
-	jsonContent, err := json.Marshal(p.Content)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal text content: %w", err)
-	}
-	return fmt.Sprintf("%c:%s\n", p.TypeID(), string(jsonContent)), nil
+	return formatJSONPart(p, true)
 }
+func (p TextStreamPart) jsonValue() any { return p.Content }
 
 // ReasoningStreamPart corresponds to TYPE_ID 'g'.
 type ReasoningStreamPart struct {
@@ -204,12 +1176,9 @@ type ReasoningStreamPart struct {
 
 func (p ReasoningStreamPart) TypeID() byte { return 'g' }
 func (p ReasoningStreamPart) Format() (string, error) {
-	jsonContent, err := json.Marshal(p.Content)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal reasoning content: %w", err)
-	}
-	return fmt.Sprintf("%c:%s\n", p.TypeID(), string(jsonContent)), nil
+	return formatJSONPart(p, true)
 }
+func (p ReasoningStreamPart) jsonValue() any { return p.Content }
 
 // RedactedReasoningStreamPart corresponds to TYPE_ID 'i'.
 type RedactedReasoningStreamPart struct {
@@ -218,8 +1187,9 @@ type RedactedReasoningStreamPart struct {
 
 func (p RedactedReasoningStreamPart) TypeID() byte { return 'i' }
 func (p RedactedReasoningStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
 }
+func (p RedactedReasoningStreamPart) jsonValue() any { return p }
 
 // ReasoningSignatureStreamPart corresponds to TYPE_ID 'j'.
 type ReasoningSignatureStreamPart struct {
@@ -228,8 +1198,9 @@ type ReasoningSignatureStreamPart struct {
 
 func (p ReasoningSignatureStreamPart) TypeID() byte { return 'j' }
 func (p ReasoningSignatureStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
 }
+func (p ReasoningSignatureStreamPart) jsonValue() any { return p }
 
 // SourceStreamPart corresponds to TYPE_ID 'h'.
 type SourceStreamPart struct {
@@ -241,8 +1212,9 @@ type SourceStreamPart struct {
 
 func (p SourceStreamPart) TypeID() byte { return 'h' }
 func (p SourceStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
 }
+func (p SourceStreamPart) jsonValue() any { return p }
 
 // FileStreamPart corresponds to TYPE_ID 'k'.
 type FileStreamPart struct {
@@ -252,8 +1224,9 @@ type FileStreamPart struct {
 
 func (p FileStreamPart) TypeID() byte { return 'k' }
 func (p FileStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
 }
+func (p FileStreamPart) jsonValue() any { return p }
 
 // DataStreamDataPart corresponds to TYPE_ID '2'.
 type DataStreamDataPart struct {
@@ -262,11 +1235,36 @@ type DataStreamDataPart struct {
 
 func (p DataStreamDataPart) TypeID() byte { return '2' }
 func (p DataStreamDataPart) Format() (string, error) {
-	jsonContent, err := json.Marshal(p.Content)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal data content: %w", err)
+	return formatJSONPart(p, true)
+}
+func (p DataStreamDataPart) jsonValue() any { return p.Content }
+
+// DataUIPart is a typed custom data part shaped like the `data-<name>` parts of
+// the AI SDK v5 UI message stream protocol. It is sent as a v1 data part
+// (TYPE_ID '2') holding that object, and parses back as a DataStreamDataPart.
+// Data parts with the same name and ID are reconciled, so servers can update
+// UI state (progress, partial results) in place.
+type DataUIPart struct {
+	Name  string
+	ID    string
+	Value any
+}
+
+func (p DataUIPart) TypeID() byte { return '2' }
+func (p DataUIPart) Format() (string, error) {
+	return formatJSONPart(p, true)
+}
+func (p DataUIPart) jsonValue() any { return []any{p.uiMessagePart()} }
+
+func (p DataUIPart) uiMessagePart() map[string]any {
+	part := map[string]any{
+		"type": "data-" + p.Name,
+		"data": p.Value,
 	}
-	return fmt.Sprintf("%c:%s\n", p.TypeID(), string(jsonContent)), nil
+	if p.ID != "" {
+		part["id"] = p.ID
+	}
+	return part
 }
 
 // MessageAnnotationStreamPart corresponds to TYPE_ID '8'.
@@ -276,12 +1274,9 @@ type MessageAnnotationStreamPart struct {
 
 func (p MessageAnnotationStreamPart) TypeID() byte { return '8' }
 func (p MessageAnnotationStreamPart) Format() (string, error) {
-	jsonContent, err := json.Marshal(p.Content)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal annotation content: %w", err)
-	}
-	return fmt.Sprintf("%c:%s\n", p.TypeID(), string(jsonContent)), nil
+	return formatJSONPart(p, true)
 }
+func (p MessageAnnotationStreamPart) jsonValue() any { return p.Content }
 
 // ErrorStreamPart corresponds to TYPE_ID '3'.
 type ErrorStreamPart struct {
@@ -290,13 +1285,23 @@ type ErrorStreamPart struct {
 
 func (p ErrorStreamPart) TypeID() byte { return '3' }
 func (p ErrorStreamPart) Format() (string, error) {
-	jsonContent, err := json.Marshal(p.Content)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal error content: %w", err)
-	}
-	return fmt.Sprintf("%c:%s\n", p.TypeID(), string(jsonContent)), nil
+	return formatJSONPart(p, true)
+}
+func (p ErrorStreamPart) jsonValue() any { return p.Content }
+
+// RefusalStreamPart is yielded when the model declines to respond, e.g. for
+// safety reasons. It is formatted as an error (TYPE_ID '3'), so that clients
+// render the refusal instead of an answer, but it doesn't fail accumulation.
+type RefusalStreamPart struct {
+	Content string
 }
 
+func (p RefusalStreamPart) TypeID() byte { return '3' }
+func (p RefusalStreamPart) Format() (string, error) {
+	return formatJSONPart(p, true)
+}
+func (p RefusalStreamPart) jsonValue() any { return p.Content }
+
 // ToolCall represents a tool call *request*.
 type ToolCall struct {
 	ID   string         `json:"id"`
@@ -304,30 +1309,58 @@ type ToolCall struct {
 	Args map[string]any `json:"args"`
 }
 
-type ToolCallResult interface {
-	Part | []Part | any
+// ToolCallResult is the result of a tool call, as returned by the handler
+// passed to WithToolCalling. A Part or []Part is sent to the provider as is,
+// so a tool can return text together with images; use ToolResult to build
+// one. Any other value is marshalled to JSON and sent as a single text part.
+type ToolCallResult any
+
+// ToolResult returns a tool call result made of the given parts, e.g. a text
+// description together with an image file.
+func ToolResult(parts ...Part) ToolCallResult {
+	return parts
 }
 
 // ToolCallStartStreamPart corresponds to TYPE_ID 'b'.
 type ToolCallStartStreamPart struct {
 	ToolCallID string `json:"toolCallId"`
 	ToolName   string `json:"toolName"`
+	// Index is the position of the call among the tool calls of its step, set
+	// by the adapters so that deltas can be routed by it.
+	Index int `json:"index,omitempty"`
 }
 
 func (p ToolCallStartStreamPart) TypeID() byte { return 'b' }
 func (p ToolCallStartStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
 }
+func (p ToolCallStartStreamPart) jsonValue() any { return p }
 
 // ToolCallDeltaStreamPart corresponds to TYPE_ID 'c'.
 type ToolCallDeltaStreamPart struct {
 	ToolCallID    string `json:"toolCallId"`
 	ArgsTextDelta string `json:"argsTextDelta"`
+	// Index is the Index of the ToolCallStartStreamPart of the call. A delta
+	// without a ToolCallID belongs to the latest call started with its Index.
+	Index int `json:"index,omitempty"`
 }
 
 func (p ToolCallDeltaStreamPart) TypeID() byte { return 'c' }
 func (p ToolCallDeltaStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
+}
+func (p ToolCallDeltaStreamPart) jsonValue() any { return p }
+
+// toolCallIDs maps the Index of started tool calls to their ID, to route
+// deltas that only carry an Index.
+type toolCallIDs map[int]string
+
+// resolve returns the delta with its ToolCallID set from its Index if missing.
+func (ids toolCallIDs) resolve(p ToolCallDeltaStreamPart) ToolCallDeltaStreamPart {
+	if p.ToolCallID == "" {
+		p.ToolCallID = ids[p.Index]
+	}
+	return p
 }
 
 // ToolCallStreamPart corresponds to TYPE_ID '9'.
@@ -339,19 +1372,24 @@ type ToolCallStreamPart struct {
 
 func (p ToolCallStreamPart) TypeID() byte { return '9' }
 func (p ToolCallStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
 }
+func (p ToolCallStreamPart) jsonValue() any { return p }
 
 // ToolResultStreamPart corresponds to TYPE_ID 'a'.
 type ToolResultStreamPart struct {
 	ToolCallID string `json:"toolCallId"`
 	Result     any    `json:"result"`
+	// IsError marks the result as a failure of the tool, e.g. because the
+	// tool call handler returned an error.
+	IsError bool `json:"isError,omitempty"`
 }
 
 func (p ToolResultStreamPart) TypeID() byte { return 'a' }
 func (p ToolResultStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
 }
+func (p ToolResultStreamPart) jsonValue() any { return p }
 
 // StartStepStreamPart corresponds to TYPE_ID 'f'.
 type StartStepStreamPart struct {
@@ -360,8 +1398,9 @@ type StartStepStreamPart struct {
 
 func (p StartStepStreamPart) TypeID() byte { return 'f' }
 func (p StartStepStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
 }
+func (p StartStepStreamPart) jsonValue() any { return p }
 
 // FinishReason defines the possible reasons for finishing a step or message.
 type FinishReason string
@@ -376,33 +1415,119 @@ const (
 	FinishReasonUnknown       FinishReason = "unknown"
 )
 
+// Usage is the token usage reported by a provider. Fields are nil when the
+// provider did not report them.
+type Usage struct {
+	PromptTokens     *int64 `json:"promptTokens"`
+	CompletionTokens *int64 `json:"completionTokens"`
+	// AcceptedPredictionTokens and RejectedPredictionTokens are the completion
+	// tokens that matched and didn't match an OpenAI predicted output.
+	AcceptedPredictionTokens *int64 `json:"acceptedPredictionTokens,omitempty"`
+	RejectedPredictionTokens *int64 `json:"rejectedPredictionTokens,omitempty"`
+}
+
+// usageAnnotation returns an annotation with the usage so far, for providers
+// that report usage while the response streams.
+func usageAnnotation(usage Usage) MessageAnnotationStreamPart {
+	annotation := map[string]any{"type": "usage"}
+	if usage.PromptTokens != nil {
+		annotation["promptTokens"] = *usage.PromptTokens
+	}
+	if usage.CompletionTokens != nil {
+		annotation["completionTokens"] = *usage.CompletionTokens
+	}
+	return MessageAnnotationStreamPart{Content: []any{annotation}}
+}
+
+// PromptTokensOrZero returns the prompt tokens, or zero if they weren't reported.
+func (u Usage) PromptTokensOrZero() int64 {
+	if u.PromptTokens == nil {
+		return 0
+	}
+	return *u.PromptTokens
+}
+
+// CompletionTokensOrZero returns the completion tokens, or zero if they weren't reported.
+func (u Usage) CompletionTokensOrZero() int64 {
+	if u.CompletionTokens == nil {
+		return 0
+	}
+	return *u.CompletionTokens
+}
+
+// Add returns the sum of both usages, treating nil token counts as zero.
+// A token count in the result is only nil if it is nil in both usages.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:             addTokens(u.PromptTokens, other.PromptTokens),
+		CompletionTokens:         addTokens(u.CompletionTokens, other.CompletionTokens),
+		AcceptedPredictionTokens: addTokens(u.AcceptedPredictionTokens, other.AcceptedPredictionTokens),
+		RejectedPredictionTokens: addTokens(u.RejectedPredictionTokens, other.RejectedPredictionTokens),
+	}
+}
+
+func addTokens(a, b *int64) *int64 {
+	if a == nil && b == nil {
+		return nil
+	}
+	var sum int64
+	if a != nil {
+		sum += *a
+	}
+	if b != nil {
+		sum += *b
+	}
+	return &sum
+}
+
 // FinishStepStreamPart corresponds to TYPE_ID 'e'.
 type FinishStepStreamPart struct {
 	FinishReason FinishReason `json:"finishReason"`
+	Usage        Usage        `json:"usage"`
 	IsContinued  bool         `json:"isContinued"`
+	// StopSequence is the stop sequence that ended the step, if any, so that
+	// callers with several sequences can tell which one matched.
+	StopSequence string `json:"stopSequence,omitempty"`
 }
 
 func (p FinishStepStreamPart) TypeID() byte { return 'e' }
 func (p FinishStepStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
 }
+func (p FinishStepStreamPart) jsonValue() any { return p }
 
 // FinishMessageStreamPart corresponds to TYPE_ID 'd'.
 type FinishMessageStreamPart struct {
 	FinishReason FinishReason `json:"finishReason"`
+	Usage        Usage        `json:"usage"`
 }
 
 func (p FinishMessageStreamPart) TypeID() byte { return 'd' }
 func (p FinishMessageStreamPart) Format() (string, error) {
-	return formatJSONPart(p)
+	return formatJSONPart(p, true)
+}
+func (p FinishMessageStreamPart) jsonValue() any { return p }
+
+// jsonPart is implemented by the parts of this package, which are formatted
+// as their TYPE_ID followed by a JSON value, so that Pipe can choose how the
+// value is encoded.
+type jsonPart interface {
+	DataStreamPart
+	jsonValue() any
 }
 
-func formatJSONPart(part DataStreamPart) (string, error) {
-	jsonData, err := json.Marshal(part)
-	if err != nil {
+// formatJSONPart formats a part with a json.Encoder, which escapes <, > and &
+// like json.Marshal unless escapeHTML is false.
+func formatJSONPart(part jsonPart, escapeHTML bool) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%c:", part.TypeID())
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(escapeHTML)
+	// Encode terminates the line with a newline.
+	if err := enc.Encode(part.jsonValue()); err != nil {
 		return "", fmt.Errorf("failed to marshal part type %T: %w", part, err)
 	}
-	return fmt.Sprintf("%c:%s\n", part.TypeID(), string(jsonData)), nil
+	return b.String(), nil
 }
 
 type Attachment struct {
@@ -421,6 +1546,74 @@ type Message struct {
 	Attachments []Attachment     `json:"experimental_attachments,omitempty"`
 }
 
+// TextContent returns the text parts of the message concatenated in order,
+// ignoring reasoning, tool invocations, sources and files. Messages without
+// parts return their Content.
+func (m Message) TextContent() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	var text strings.Builder
+	for _, part := range m.Parts {
+		if part.Type == PartTypeText {
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String()
+}
+
+// ReasoningContent returns the reasoning parts of the message concatenated in order.
+func (m Message) ReasoningContent() string {
+	var reasoning strings.Builder
+	for _, part := range m.Parts {
+		if part.Type == PartTypeReasoning {
+			reasoning.WriteString(part.Reasoning)
+		}
+	}
+	return reasoning.String()
+}
+
+// EnsureSystemMessage returns messages with a system message of the content
+// prepended, unless they already contain a system or developer message. It is
+// idempotent, so it can be called on every turn of a conversation. The input is
+// not modified.
+func EnsureSystemMessage(messages []Message, content string) []Message {
+	for _, message := range messages {
+		if message.Role == "system" || message.Role == "developer" {
+			return messages
+		}
+	}
+	system := Message{
+		Role:    "system",
+		Content: content,
+		Parts:   []Part{{Type: PartTypeText, Text: content}},
+	}
+	return append([]Message{system}, messages...)
+}
+
+// StripReasoning returns a copy of messages with the reasoning parts removed
+// from assistant messages, e.g. before resending a conversation to a provider
+// that doesn't accept reasoning as input. The input is not modified.
+func StripReasoning(messages []Message) []Message {
+	stripped := make([]Message, len(messages))
+	for i, message := range messages {
+		stripped[i] = message
+		if message.Role != "assistant" || len(message.Parts) == 0 {
+			continue
+		}
+		parts := make([]Part, 0, len(message.Parts))
+		for _, part := range message.Parts {
+			if part.Type == PartTypeReasoning {
+				continue
+			}
+			part.Details = nil
+			parts = append(parts, part)
+		}
+		stripped[i].Parts = parts
+	}
+	return stripped
+}
+
 type PartType string
 
 const (
@@ -465,12 +1658,18 @@ type Part struct {
 	// Type: "file"
 	MimeType string `json:"mimeType,omitempty"`
 	Data     []byte `json:"data,omitempty"`
+	// URL references a hosted file instead of inlining it in Data.
+	URL string `json:"url,omitempty"`
 
 	// Type: "step-start" - No additional fields
 
 	isComplete bool `json:"-"` // Internal accumulator tracking
 }
 
+// ThinkingBudget is the number of tokens a model may spend on reasoning before
+// it answers. Zero disables thinking.
+type ThinkingBudget int64
+
 type Tool struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
@@ -482,6 +1681,32 @@ type Schema struct {
 	Properties map[string]any `json:"properties"`
 }
 
+// JSONSchema renders the parameters of the tool as a JSON Schema document, e.g.
+// for the input schema of an MCP tool, without depending on a provider.
+func (t Tool) JSONSchema() ([]byte, error) {
+	data, err := json.Marshal(t.Schema.jsonSchema())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema of tool %s: %w", t.Name, err)
+	}
+	return data, nil
+}
+
+// jsonSchema returns the schema as a JSON Schema object.
+func (s Schema) jsonSchema() map[string]any {
+	properties := s.Properties
+	if properties == nil {
+		properties = map[string]any{}
+	}
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(s.Required) > 0 {
+		schema["required"] = s.Required
+	}
+	return schema
+}
+
 type ToolInvocationState string
 
 const (
@@ -497,20 +1722,57 @@ type ToolInvocation struct {
 	ToolName   string              `json:"toolName"`
 	Args       any                 `json:"args"`
 	Result     any                 `json:"result,omitempty"`
+	// IsError marks the result as a failure of the tool, so providers that
+	// support it can tell the model the tool failed.
+	IsError bool `json:"isError,omitempty"`
 }
 
+// WriteDataStreamHeaders writes the headers of the v1 data stream protocol.
 func WriteDataStreamHeaders(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Header().Set("X-Vercel-AI-Data-Stream", "v1")
 	w.WriteHeader(http.StatusOK)
 }
 
+// WriteUIMessageStreamHeaders writes the headers of the AI SDK v5 UI message
+// stream protocol, which is sent as server-sent events.
+func WriteUIMessageStreamHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Vercel-AI-UI-Message-Stream", "v1")
+	// Disable response buffering in proxies like nginx.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+}
+
 // DataStreamAccumulator accumulates DataStreamParts into Messages.
 type DataStreamAccumulator struct {
+	// UseNumber decodes numbers in streamed tool call args as json.Number
+	// instead of float64, so that integers like IDs round-trip unchanged.
+	UseNumber bool
+	// PartialToolArgs exposes the args parsed so far on tool calls that are
+	// still streaming, like the client does for live previews. The args are
+	// re-parsed on every delta, which is quadratic in their length, so it is
+	// off by default and the args are set once the call completes.
+	PartialToolArgs bool
+	// GenerateID returns the ID of a message whose stream doesn't provide
+	// one, like OpenAI's, e.g. a ULID or a database ID. If nil, such messages
+	// have an empty ID.
+	GenerateID func() string
+
 	messages       []Message
 	currentMessage *Message
-	wipToolCalls   map[string]*Part // Keyed by ToolCallID, points to Part in currentMessage.Parts
+	wipToolCalls   map[string]*strings.Builder // Keyed by ToolCallID, holds the args text of partial calls
+	wipToolCallIDs toolCallIDs                 // Keyed by Index, for deltas without a ToolCallID
 	finishReason   FinishReason
+	usage          Usage
+	stepUsages     []Usage
+	stepFinished   bool
+	steps          int // Number of steps in currentMessage
+	stepStart      int // Index of the current step's step-start part in currentMessage
+	stepList       []Step
+	refusal        string
 }
 
 func (a *DataStreamAccumulator) ensureCurrentMessage() {
@@ -519,10 +1781,93 @@ func (a *DataStreamAccumulator) ensureCurrentMessage() {
 			Role:  "assistant",
 			Parts: make([]Part, 0, 5),
 		}
-		a.wipToolCalls = make(map[string]*Part)
+		a.wipToolCalls = make(map[string]*strings.Builder)
+		a.wipToolCallIDs = make(toolCallIDs)
+		a.stepStart = 0
 	}
 }
 
+// ensureMessageID generates the ID of the current message if the stream
+// didn't provide one by now. The ID then stays the same.
+func (a *DataStreamAccumulator) ensureMessageID() {
+	if a.currentMessage.ID == "" && a.GenerateID != nil {
+		a.currentMessage.ID = a.GenerateID()
+	}
+}
+
+// completeToolCalls moves the remaining partial tool calls to the call state
+// once their args are complete. Calls whose args never parsed are left in the
+// partial-call state.
+func (a *DataStreamAccumulator) completeToolCalls() {
+	for id, builder := range a.wipToolCalls {
+		delete(a.wipToolCalls, id)
+		text := builder.String()
+		wipCallPart := a.findPart(id)
+		if wipCallPart == nil || wipCallPart.isComplete {
+			continue
+		}
+		wipCallPart.isComplete = true
+
+		// Tools without parameters may stream no args at all.
+		args := map[string]any{}
+		if strings.TrimSpace(text) != "" {
+			var err error
+			if args, err = decodeToolArgs(text, a.UseNumber); err != nil {
+				continue
+			}
+		}
+		wipCallPart.ToolInvocation.Args = args
+		wipCallPart.ToolInvocation.State = ToolInvocationStateCall
+	}
+}
+
+// reasoningPart returns the reasoning part at the end of the current message,
+// adding one if it ends with another part, so that reasoning interleaved with
+// text or tool calls keeps its order.
+func (a *DataStreamAccumulator) reasoningPart() *Part {
+	parts := a.currentMessage.Parts
+	if len(parts) > 0 && parts[len(parts)-1].Type == PartTypeReasoning {
+		return &parts[len(parts)-1]
+	}
+	a.currentMessage.Parts = append(a.currentMessage.Parts, Part{Type: PartTypeReasoning})
+	return &a.currentMessage.Parts[len(a.currentMessage.Parts)-1]
+}
+
+// lastReasoningPart returns the last reasoning part of the current message, or
+// nil if there is none.
+func (a *DataStreamAccumulator) lastReasoningPart() *Part {
+	for i := len(a.currentMessage.Parts) - 1; i >= 0; i-- {
+		if a.currentMessage.Parts[i].Type == PartTypeReasoning {
+			return &a.currentMessage.Parts[i]
+		}
+	}
+	return nil
+}
+
+// stepIndex returns the zero-based index of the current step in the current
+// message, which the client uses to group tool invocations by step.
+func (a *DataStreamAccumulator) stepIndex() *int {
+	step := max(a.steps-1, 0)
+	return &step
+}
+
+// finishStep records the parts of the current step as a Step.
+func (a *DataStreamAccumulator) finishStep(finishReason FinishReason, usage Usage, stopSequence string) {
+	a.ensureMessageID()
+	message := Message{
+		ID:    a.currentMessage.ID,
+		Role:  a.currentMessage.Role,
+		Parts: slices.Clone(a.currentMessage.Parts[a.stepStart:]),
+	}
+	message.Content = message.TextContent()
+	a.stepList = append(a.stepList, Step{
+		Message:      message,
+		FinishReason: finishReason,
+		Usage:        usage,
+		StopSequence: stopSequence,
+	})
+}
+
 func (a *DataStreamAccumulator) findPart(toolCallID string) *Part {
 	if a.currentMessage == nil {
 		return nil
@@ -567,18 +1912,40 @@ func (a *DataStreamAccumulator) Push(part DataStreamPart) error {
 		if currentMsgPtr == nil {
 			return fmt.Errorf("cannot add ReasoningStreamPart without an active message")
 		}
-		var reasoningPart *Part
-		for i := range currentMsgPtr.Parts {
-			if currentMsgPtr.Parts[i].Type == PartTypeReasoning {
-				reasoningPart = &currentMsgPtr.Parts[i]
+		reasoningPart := a.reasoningPart()
+		reasoningPart.Reasoning += p.Content
+
+		// Signed reasoning is kept in details, so it can be sent back to
+		// providers that verify it. A signature ends a text detail.
+		details := reasoningPart.Details
+		if len(details) > 0 && details[len(details)-1].Type == "text" && details[len(details)-1].Signature == "" {
+			details[len(details)-1].Text += p.Content
+		} else {
+			reasoningPart.Details = append(details, ReasoningDetail{Type: "text", Text: p.Content})
+		}
+
+	case ReasoningSignatureStreamPart:
+		if currentMsgPtr == nil {
+			return fmt.Errorf("cannot add ReasoningSignatureStreamPart without an active message")
+		}
+		if reasoningPart := a.lastReasoningPart(); reasoningPart != nil {
+			details := reasoningPart.Details
+			if len(details) > 0 && details[len(details)-1].Type == "text" && details[len(details)-1].Signature == "" {
+				details[len(details)-1].Signature = p.Signature
 				break
 			}
 		}
-		if reasoningPart == nil {
-			currentMsgPtr.Parts = append(currentMsgPtr.Parts, Part{Type: PartTypeReasoning})
-			reasoningPart = &currentMsgPtr.Parts[len(currentMsgPtr.Parts)-1]
+		// Anthropic signs empty thinking blocks too, so a signature without
+		// reasoning to sign is kept on an empty reasoning detail.
+		reasoningPart := a.reasoningPart()
+		reasoningPart.Details = append(reasoningPart.Details, ReasoningDetail{Type: "text", Signature: p.Signature})
+
+	case RedactedReasoningStreamPart:
+		if currentMsgPtr == nil {
+			return fmt.Errorf("cannot add RedactedReasoningStreamPart without an active message")
 		}
-		reasoningPart.Reasoning += p.Content
+		reasoningPart := a.reasoningPart()
+		reasoningPart.Details = append(reasoningPart.Details, ReasoningDetail{Type: "redacted", Data: p.Data})
 
 	case FileStreamPart:
 		if currentMsgPtr == nil {
@@ -611,7 +1978,15 @@ func (a *DataStreamAccumulator) Push(part DataStreamPart) error {
 		if currentMsgPtr.ID == "" {
 			currentMsgPtr.ID = p.MessageID
 		}
+		// A step that started without any content is the same step, so
+		// redundant step starts don't leave empty steps behind.
+		if n := len(currentMsgPtr.Parts); n > 0 && currentMsgPtr.Parts[n-1].Type == PartTypeStepStart {
+			break
+		}
+		a.stepStart = len(currentMsgPtr.Parts)
 		currentMsgPtr.Parts = append(currentMsgPtr.Parts, Part{Type: PartTypeStepStart})
+		a.stepFinished = false
+		a.steps++
 
 	case ToolCallStartStreamPart:
 		if currentMsgPtr == nil {
@@ -622,25 +1997,34 @@ func (a *DataStreamAccumulator) Push(part DataStreamPart) error {
 			Type: PartTypeToolInvocation,
 			ToolInvocation: &ToolInvocation{
 				State:      ToolInvocationStatePartialCall,
+				Step:       a.stepIndex(),
 				ToolCallID: p.ToolCallID,
 				ToolName:   p.ToolName,
-				Args:       "",
+				Args:       map[string]any{},
 			},
 			isComplete: false,
 		}
 		currentMsgPtr.Parts = append(currentMsgPtr.Parts, newPart)
-		a.wipToolCalls[p.ToolCallID] = &currentMsgPtr.Parts[len(currentMsgPtr.Parts)-1]
+		a.wipToolCalls[p.ToolCallID] = &strings.Builder{}
+		a.wipToolCallIDs[p.Index] = p.ToolCallID
 
 	case ToolCallDeltaStreamPart:
 		if currentMsgPtr == nil {
 			return fmt.Errorf("cannot add ToolCallDeltaStreamPart without an active message")
 		}
-		wipCallPart, exists := a.wipToolCalls[p.ToolCallID]
-		if exists && wipCallPart.ToolInvocation != nil {
-			if argsStr, ok := wipCallPart.ToolInvocation.Args.(string); ok {
-				wipCallPart.ToolInvocation.Args = argsStr + p.ArgsTextDelta
-			} else {
-				return fmt.Errorf("tool call delta received for non-string args (ID: %s)", p.ToolCallID)
+		p = a.wipToolCallIDs.resolve(p)
+		builder, exists := a.wipToolCalls[p.ToolCallID]
+		if !exists {
+			break
+		}
+		builder.WriteString(p.ArgsTextDelta)
+
+		if !a.PartialToolArgs {
+			break
+		}
+		if args, ok := parsePartialJSON(builder.String(), a.UseNumber); ok {
+			if wipCallPart := a.findPart(p.ToolCallID); wipCallPart != nil {
+				wipCallPart.ToolInvocation.Args = args
 			}
 		}
 
@@ -660,6 +2044,7 @@ func (a *DataStreamAccumulator) Push(part DataStreamPart) error {
 				Type: PartTypeToolInvocation,
 				ToolInvocation: &ToolInvocation{
 					State:      ToolInvocationStateCall,
+					Step:       a.stepIndex(),
 					ToolCallID: p.ToolCallID,
 					ToolName:   p.ToolName,
 					Args:       p.Args,
@@ -678,6 +2063,7 @@ func (a *DataStreamAccumulator) Push(part DataStreamPart) error {
 		if existingPart != nil && existingPart.ToolInvocation != nil {
 			existingPart.ToolInvocation.State = ToolInvocationStateResult
 			existingPart.ToolInvocation.Result = p.Result
+			existingPart.ToolInvocation.IsError = p.IsError
 		} else {
 			return fmt.Errorf("tool result received for unknown tool call ID: %s", p.ToolCallID)
 		}
@@ -688,6 +2074,22 @@ func (a *DataStreamAccumulator) Push(part DataStreamPart) error {
 		}
 		currentMsgPtr.Annotations = append(currentMsgPtr.Annotations, p.Content...)
 
+	case DataUIPart:
+		if currentMsgPtr == nil {
+			return fmt.Errorf("cannot add DataUIPart without an active message")
+		}
+		annotation := p.uiMessagePart()
+		if p.ID != "" {
+			// Replace the data part with the same name and ID, like the client does.
+			for i, existing := range currentMsgPtr.Annotations {
+				if existing, ok := existing.(map[string]any); ok && existing["type"] == annotation["type"] && existing["id"] == p.ID {
+					currentMsgPtr.Annotations[i] = annotation
+					return nil
+				}
+			}
+		}
+		currentMsgPtr.Annotations = append(currentMsgPtr.Annotations, annotation)
+
 	case MessageAnnotationStreamPart:
 		if currentMsgPtr == nil {
 			return fmt.Errorf("cannot add MessageAnnotationStreamPart without an active message")
@@ -696,56 +2098,55 @@ func (a *DataStreamAccumulator) Push(part DataStreamPart) error {
 
 	case FinishStepStreamPart:
 		if currentMsgPtr != nil {
-			// Clean up any remaining WIP tool calls
-			for id, wipCallPart := range a.wipToolCalls {
-				if !wipCallPart.isComplete && wipCallPart.ToolInvocation != nil {
-					if argsStr, ok := wipCallPart.ToolInvocation.Args.(string); ok && argsStr != "" {
-						var parsedArgs map[string]any
-						if json.Unmarshal([]byte(argsStr), &parsedArgs) == nil {
-							wipCallPart.ToolInvocation.Args = parsedArgs
-							wipCallPart.ToolInvocation.State = ToolInvocationStateCall
-						}
-					}
-					wipCallPart.isComplete = true
-				}
-				delete(a.wipToolCalls, id)
-			}
+			a.completeToolCalls()
+			a.finishStep(p.FinishReason, p.Usage, p.StopSequence)
 
-			if !p.IsContinued {
+			// A tool call step is followed by a step that responds to the
+			// tool results, like in an agent loop, so the message stays open
+			// until the message finishes.
+			if !p.IsContinued && p.FinishReason != FinishReasonToolCalls {
 				a.messages = append(a.messages, *currentMsgPtr)
 				a.currentMessage = nil
 				a.wipToolCalls = nil
+				a.wipToolCallIDs = nil
+				a.steps = 0
 			}
 		}
 		a.finishReason = p.FinishReason
+		a.stepUsages = append(a.stepUsages, p.Usage)
+		a.stepFinished = true
 
 	case FinishMessageStreamPart:
 		if currentMsgPtr != nil {
-			// Clean up any remaining WIP tool calls
-			for _, wipCallPart := range a.wipToolCalls {
-				if !wipCallPart.isComplete && wipCallPart.ToolInvocation != nil {
-					if argsStr, ok := wipCallPart.ToolInvocation.Args.(string); ok && argsStr != "" {
-						var parsedArgs map[string]any
-						if json.Unmarshal([]byte(argsStr), &parsedArgs) == nil {
-							wipCallPart.ToolInvocation.Args = parsedArgs
-							wipCallPart.ToolInvocation.State = ToolInvocationStateCall
-						}
-					}
-					wipCallPart.isComplete = true
-				}
+			a.completeToolCalls()
+			if !a.stepFinished {
+				a.finishStep(p.FinishReason, p.Usage, "")
 			}
+			a.ensureMessageID()
 			a.messages = append(a.messages, *currentMsgPtr)
 		}
+		if !a.stepFinished {
+			// The stream ended without finishing its last step, so the
+			// message usage is the only usage reported for it.
+			a.stepUsages = append(a.stepUsages, p.Usage)
+		}
 		a.finishReason = p.FinishReason
+		a.usage = p.Usage
+		a.stepFinished = true
 		a.currentMessage = nil
 		a.wipToolCalls = nil
+		a.wipToolCallIDs = nil
+		a.steps = 0
 
 	case ErrorStreamPart:
 		a.finishReason = FinishReasonError
 		return fmt.Errorf("error in stream: %s", p.Content)
 
-	case RedactedReasoningStreamPart, ReasoningSignatureStreamPart:
-		// No action needed for accumulation
+	case RefusalStreamPart:
+		a.refusal += p.Content
+
+	case CursorStreamPart:
+		// Cursors are only relevant to the transport.
 
 	default:
 		return fmt.Errorf("unhandled part type: %T", part)
@@ -758,10 +2159,164 @@ func (a *DataStreamAccumulator) Messages() []Message {
 	return a.messages
 }
 
+// CurrentMessage returns a copy of the message that is being accumulated, and
+// false if there is none. Tool invocations that are still streaming are in the
+// partial-call state with the args parsed so far, e.g. to render live previews.
+func (a *DataStreamAccumulator) CurrentMessage() (Message, bool) {
+	if a.currentMessage == nil {
+		return Message{}, false
+	}
+	a.ensureMessageID()
+	message := *a.currentMessage
+	message.Parts = make([]Part, len(a.currentMessage.Parts))
+	for i, part := range a.currentMessage.Parts {
+		if part.ToolInvocation != nil {
+			invocation := *part.ToolInvocation
+			part.ToolInvocation = &invocation
+		}
+		message.Parts[i] = part
+	}
+	return message, true
+}
+
 func (a *DataStreamAccumulator) FinishReason() FinishReason {
 	return a.finishReason
 }
 
+// Usage returns the usage reported by the final FinishMessageStreamPart.
+func (a *DataStreamAccumulator) Usage() Usage {
+	return a.usage
+}
+
+// Refusal returns the content of the RefusalStreamParts seen, or an empty
+// string if the model didn't decline to respond.
+func (a *DataStreamAccumulator) Refusal() string {
+	return a.refusal
+}
+
+// Step is a single step of an accumulated response, like a tool call or the
+// answer that follows its result.
+type Step struct {
+	// Message holds the parts of the step, starting with its step-start part.
+	// Its ID and role are those of the message the step belongs to.
+	Message      Message
+	FinishReason FinishReason
+	Usage        Usage
+	// StopSequence is the stop sequence that ended the step, if any.
+	StopSequence string
+}
+
+// Steps returns every finished step in order, each with its own finish reason
+// and usage, regardless of whether the steps were merged into one message with
+// IsContinued. Tool invocations are shared with Messages, so a result that
+// arrives after its step finished is visible in both.
+func (a *DataStreamAccumulator) Steps() []Step {
+	return slices.Clone(a.stepList)
+}
+
+// TotalUsage returns the usage summed across every FinishStepStreamPart seen,
+// which is the total for multi-step (e.g. tool calling) conversations. If the
+// stream finished without finishing its last step, the usage of the
+// FinishMessageStreamPart is counted for that step. See Usage.Add for how
+// nil token counts are handled.
+func (a *DataStreamAccumulator) TotalUsage() Usage {
+	var total Usage
+	for _, usage := range a.stepUsages {
+		total = total.Add(usage)
+	}
+	return total
+}
+
+// partsToMessage accumulates the parts of a complete, non-streaming response
+// into a single message, so that it has the same representation as a stream.
+func partsToMessage(parts []DataStreamPart) (Message, error) {
+	var acc DataStreamAccumulator
+	for _, part := range parts {
+		if err := acc.Push(part); err != nil {
+			return Message{}, err
+		}
+	}
+	messages := acc.Messages()
+	if len(messages) != 1 {
+		return Message{}, fmt.Errorf("expected a single message, got %d", len(messages))
+	}
+	return messages[0], nil
+}
+
+// PendingToolCalls returns the tool calls of the message that don't have a
+// result yet, in order, e.g. to decide in an agent loop whether to run tools
+// before the next request. Calls whose arguments are still streaming are
+// skipped, since they can't be run yet.
+func PendingToolCalls(msg Message) []ToolCall {
+	var calls []ToolCall
+	for _, part := range msg.Parts {
+		if part.Type != PartTypeToolInvocation || part.ToolInvocation == nil || part.ToolInvocation.State != ToolInvocationStateCall {
+			continue
+		}
+		args, _ := part.ToolInvocation.Args.(map[string]any)
+		calls = append(calls, ToolCall{
+			ID:   part.ToolInvocation.ToolCallID,
+			Name: part.ToolInvocation.ToolName,
+			Args: args,
+		})
+	}
+	return calls
+}
+
+// SplitToolInvocation splits a tool-invocation part into the tool call and its
+// result, which providers expect in separate messages. The call has no result
+// and the "call" state. The result is the zero Part if the invocation doesn't
+// have a result yet. Parts that aren't tool invocations are returned as the call.
+func SplitToolInvocation(part Part) (call Part, result Part) {
+	if part.Type != PartTypeToolInvocation || part.ToolInvocation == nil {
+		return part, Part{}
+	}
+
+	callInvocation := *part.ToolInvocation
+	callInvocation.Result = nil
+	if callInvocation.State == ToolInvocationStateResult {
+		callInvocation.State = ToolInvocationStateCall
+	}
+	call = Part{Type: PartTypeToolInvocation, ToolInvocation: &callInvocation}
+
+	if part.ToolInvocation.State != ToolInvocationStateResult {
+		return call, Part{}
+	}
+	resultInvocation := *part.ToolInvocation
+	result = Part{Type: PartTypeToolInvocation, ToolInvocation: &resultInvocation}
+	return call, result
+}
+
+// checkImageType returns an error naming the supported types if the provider
+// doesn't support the image MIME type. An empty type is left to the provider,
+// e.g. for images referenced by URL.
+func checkImageType(provider string, mimeType string, supported []string) error {
+	if mimeType == "" || slices.Contains(supported, mimeType) {
+		return nil
+	}
+	return fmt.Errorf("unsupported image type %q for %s, supported types are %s", mimeType, provider, strings.Join(supported, ", "))
+}
+
+// decodeToolArgs decodes the JSON object of tool call args. With useNumber,
+// numbers are decoded as json.Number instead of float64.
+func decodeToolArgs(text string, useNumber bool) (map[string]any, error) {
+	var args map[string]any
+	if !useNumber {
+		err := json.Unmarshal([]byte(text), &args)
+		return args, err
+	}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if err := decoder.Decode(&args); err != nil {
+		return nil, err
+	}
+	// Like json.Unmarshal, reject anything after the object.
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid character after tool call args")
+	}
+	return args, nil
+}
+
 func toolResultToParts(result any) ([]Part, error) {
 	switch r := result.(type) {
 	case []Part:
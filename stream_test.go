@@ -1,7 +1,16 @@
 package aisdk_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/morecommits/aisdk-go"
 	"github.com/stretchr/testify/require"
@@ -80,6 +89,11 @@ func int64Ptr(i int64) *int64 {
 	return &i
 }
 
+// Helper function to create a pointer to an int
+func intPtr(i int) *int {
+	return &i
+}
+
 func TestDataStreamAccumulator_ToolCall(t *testing.T) {
 	t.Parallel()
 
@@ -113,6 +127,7 @@ func TestDataStreamAccumulator_ToolCall(t *testing.T) {
 					Type: aisdk.PartTypeToolInvocation,
 					ToolInvocation: &aisdk.ToolInvocation{
 						State:      aisdk.ToolInvocationStateResult,
+						Step:       intPtr(0),
 						ToolCallID: "tool_123",
 						ToolName:   "get_weather",
 						Args:       map[string]any{"location": "San Francisco"},
@@ -132,3 +147,1348 @@ func TestDataStreamAccumulator_ToolCall(t *testing.T) {
 	messages := acc.Messages()
 	require.EqualExportedValues(t, expectedMessages, messages)
 }
+
+func TestDataStreamAccumulator_TotalUsage(t *testing.T) {
+	t.Parallel()
+
+	parts := []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStreamPart{
+			ToolCallID: "tool_123",
+			ToolName:   "get_weather",
+			Args:       map[string]any{"location": "San Francisco"},
+		},
+		aisdk.ToolResultStreamPart{
+			ToolCallID: "tool_123",
+			Result:     map[string]any{"temperature": 72},
+		},
+		aisdk.FinishStepStreamPart{
+			FinishReason: aisdk.FinishReasonToolCalls,
+			Usage:        aisdk.Usage{PromptTokens: int64Ptr(100), CompletionTokens: int64Ptr(20)},
+			IsContinued:  true,
+		},
+		aisdk.StartStepStreamPart{MessageID: "msg_2"},
+		aisdk.TextStreamPart{Content: "It's 72 degrees."},
+		aisdk.FinishStepStreamPart{
+			FinishReason: aisdk.FinishReasonStop,
+			// Some providers only report one of the counts.
+			Usage: aisdk.Usage{CompletionTokens: int64Ptr(10)},
+		},
+		aisdk.FinishMessageStreamPart{
+			FinishReason: aisdk.FinishReasonStop,
+			Usage:        aisdk.Usage{CompletionTokens: int64Ptr(10)},
+		},
+	}
+
+	var acc aisdk.DataStreamAccumulator
+	for _, part := range parts {
+		require.NoError(t, acc.Push(part))
+	}
+
+	require.Equal(t, aisdk.Usage{CompletionTokens: int64Ptr(10)}, acc.Usage())
+	require.Equal(t, aisdk.Usage{PromptTokens: int64Ptr(100), CompletionTokens: int64Ptr(30)}, acc.TotalUsage())
+}
+
+func TestUsage_Add(t *testing.T) {
+	t.Parallel()
+
+	var empty aisdk.Usage
+	require.Equal(t, int64(0), empty.PromptTokensOrZero())
+	require.Equal(t, int64(0), empty.CompletionTokensOrZero())
+	require.Equal(t, empty, empty.Add(aisdk.Usage{}))
+
+	// Anthropic-style usage with only completion tokens.
+	partial := aisdk.Usage{CompletionTokens: int64Ptr(5)}
+	full := aisdk.Usage{PromptTokens: int64Ptr(10), CompletionTokens: int64Ptr(20)}
+
+	sum := partial.Add(full)
+	require.Equal(t, aisdk.Usage{PromptTokens: int64Ptr(10), CompletionTokens: int64Ptr(25)}, sum)
+	require.Equal(t, int64(10), sum.PromptTokensOrZero())
+	require.Equal(t, int64(25), sum.CompletionTokensOrZero())
+
+	// Adding must not mutate either operand.
+	require.Equal(t, aisdk.Usage{CompletionTokens: int64Ptr(5)}, partial)
+	require.Equal(t, aisdk.Usage{PromptTokens: int64Ptr(10), CompletionTokens: int64Ptr(20)}, full)
+}
+
+func TestDataStream_Record(t *testing.T) {
+	t.Parallel()
+
+	parts := []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStartStreamPart{ToolCallID: "tool_1", ToolName: "print"},
+		aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `{"message":"hi"}`},
+		aisdk.TextStreamPart{Content: "Done"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	}
+	stream := partsStream(parts...)
+
+	var recording strings.Builder
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range stream.Record(&recording).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, `f:{"messageId":"msg_1"}
+b:{"toolCallId":"tool_1","toolName":"print"}
+c:{"toolCallId":"tool_1","argsTextDelta":"{\"message\":\"hi\"}"}
+0:"Done"
+d:{"finishReason":"stop","usage":{"promptTokens":null,"completionTokens":null}}
+`, recording.String())
+	require.Len(t, acc.Messages(), 1)
+}
+
+func TestDataStream_OnTextAndReasoning(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ReasoningStreamPart{Content: "The user "},
+		aisdk.ReasoningStreamPart{Content: "greeted me."},
+		aisdk.TextStreamPart{Content: "Hello"},
+		aisdk.TextStreamPart{Content: " there!"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	)
+
+	var text, reasoning []string
+	var count int
+	stream = stream.OnText(func(delta string) {
+		text = append(text, delta)
+	}).OnReasoning(func(delta string) {
+		reasoning = append(reasoning, delta)
+	})
+	for _, err := range stream {
+		require.NoError(t, err)
+		count++
+	}
+
+	require.Equal(t, []string{"Hello", " there!"}, text)
+	require.Equal(t, []string{"The user ", "greeted me."}, reasoning)
+	require.Equal(t, 6, count)
+}
+
+// partsStream returns a DataStream that yields the given parts.
+func partsStream(parts ...aisdk.DataStreamPart) aisdk.DataStream {
+	return func(yield func(aisdk.DataStreamPart, error) bool) {
+		for _, part := range parts {
+			if !yield(part, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestDataStreamAccumulator_ToolCallSteps(t *testing.T) {
+	t.Parallel()
+
+	parts := []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "search", Args: map[string]any{"query": "weather"}},
+		aisdk.ToolResultStreamPart{ToolCallID: "tool_1", Result: "sunny"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls, IsContinued: true},
+		aisdk.StartStepStreamPart{MessageID: "msg_2"},
+		aisdk.ToolCallStartStreamPart{ToolCallID: "tool_2", ToolName: "print"},
+		aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_2", ArgsTextDelta: `{"message":"sunny"}`},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+	}
+
+	var acc aisdk.DataStreamAccumulator
+	for _, part := range parts {
+		require.NoError(t, acc.Push(part))
+	}
+
+	messages := acc.Messages()
+	require.Len(t, messages, 1)
+
+	var steps []int
+	for _, part := range messages[0].Parts {
+		if part.Type == aisdk.PartTypeToolInvocation {
+			require.NotNil(t, part.ToolInvocation.Step)
+			steps = append(steps, *part.ToolInvocation.Step)
+		}
+	}
+	require.Equal(t, []int{0, 1}, steps)
+}
+
+func TestSplitToolInvocation(t *testing.T) {
+	t.Parallel()
+
+	part := aisdk.Part{
+		Type: aisdk.PartTypeToolInvocation,
+		ToolInvocation: &aisdk.ToolInvocation{
+			State:      aisdk.ToolInvocationStateResult,
+			ToolCallID: "tool_1",
+			ToolName:   "get_weather",
+			Args:       map[string]any{"location": "San Francisco"},
+			Result:     map[string]any{"temperature": 72},
+		},
+	}
+
+	call, result := aisdk.SplitToolInvocation(part)
+	require.Equal(t, aisdk.Part{
+		Type: aisdk.PartTypeToolInvocation,
+		ToolInvocation: &aisdk.ToolInvocation{
+			State:      aisdk.ToolInvocationStateCall,
+			ToolCallID: "tool_1",
+			ToolName:   "get_weather",
+			Args:       map[string]any{"location": "San Francisco"},
+		},
+	}, call)
+	require.Equal(t, part, result)
+
+	// The original part must not be modified.
+	require.Equal(t, aisdk.ToolInvocationStateResult, part.ToolInvocation.State)
+	require.NotNil(t, part.ToolInvocation.Result)
+
+	// A pending call has no result.
+	call, result = aisdk.SplitToolInvocation(aisdk.Part{
+		Type: aisdk.PartTypeToolInvocation,
+		ToolInvocation: &aisdk.ToolInvocation{
+			State:      aisdk.ToolInvocationStateCall,
+			ToolCallID: "tool_2",
+			ToolName:   "get_weather",
+		},
+	})
+	require.Equal(t, "tool_2", call.ToolInvocation.ToolCallID)
+	require.Equal(t, aisdk.Part{}, result)
+}
+
+func TestErrorDataStream(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	err := aisdk.ErrorDataStream(errors.New("invalid API key")).Pipe(&out)
+	require.NoError(t, err)
+	require.Equal(t, "3:\"invalid API key\"\n", out.String())
+}
+
+func TestDataUIPart(t *testing.T) {
+	t.Parallel()
+
+	formatted, err := aisdk.DataUIPart{
+		Name:  "progress",
+		ID:    "upload_1",
+		Value: map[string]any{"percent": 50},
+	}.Format()
+	require.NoError(t, err)
+	require.Equal(t, "2:[{\"data\":{\"percent\":50},\"id\":\"upload_1\",\"type\":\"data-progress\"}]\n", formatted)
+
+	parts := []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.DataUIPart{Name: "progress", ID: "upload_1", Value: 50},
+		aisdk.DataUIPart{Name: "progress", ID: "upload_2", Value: 10},
+		aisdk.DataUIPart{Name: "progress", ID: "upload_1", Value: 100},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	}
+	var acc aisdk.DataStreamAccumulator
+	for _, part := range parts {
+		require.NoError(t, acc.Push(part))
+	}
+
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, []any{
+		map[string]any{"type": "data-progress", "id": "upload_1", "data": 100},
+		map[string]any{"type": "data-progress", "id": "upload_2", "data": 10},
+	}, acc.Messages()[0].Annotations)
+}
+
+func TestDataUIPart_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	err := partsStream(
+		aisdk.DataUIPart{Name: "progress", ID: "upload_1", Value: map[string]any{"percent": 50}},
+	).Pipe(&out)
+	require.NoError(t, err)
+
+	var parts []aisdk.DataStreamPart
+	for part, err := range aisdk.ParseDataStream(strings.NewReader(out.String())) {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+	require.Equal(t, []aisdk.DataStreamPart{
+		aisdk.DataStreamDataPart{Content: []any{
+			map[string]any{"type": "data-progress", "id": "upload_1", "data": map[string]any{"percent": float64(50)}},
+		}},
+	}, parts)
+}
+
+func TestDataStream_WithBatchedToolCalls(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStartStreamPart{ToolCallID: "tool_1", ToolName: "print"},
+		aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `{"message":`},
+		aisdk.ToolCallStartStreamPart{ToolCallID: "tool_2", ToolName: "now"},
+		aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `"hi"}`},
+		// Providers like Anthropic send the complete call as well.
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "print", Args: map[string]any{"message": "hi"}},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_2", ToolName: "now", Args: map[string]any{}},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+	).WithBatchedToolCalls().WithToolCalling(func(toolCall aisdk.ToolCall) any {
+		return toolCall.Name
+	})
+
+	var out strings.Builder
+	err := stream.Pipe(&out)
+	require.NoError(t, err)
+	require.Equal(t, `f:{"messageId":"msg_1"}
+9:{"toolCallId":"tool_1","toolName":"print","args":{"message":"hi"}}
+a:{"toolCallId":"tool_1","result":"print"}
+9:{"toolCallId":"tool_2","toolName":"now","args":{}}
+a:{"toolCallId":"tool_2","result":"now"}
+e:{"finishReason":"tool-calls","usage":{"promptTokens":null,"completionTokens":null},"isContinued":false}
+d:{"finishReason":"tool-calls","usage":{"promptTokens":null,"completionTokens":null}}
+`, out.String())
+}
+
+func TestDataStream_WithPartialJSON(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: `{"title":"Hel`},
+		aisdk.TextStreamPart{Content: `lo\`},
+		aisdk.TextStreamPart{Content: `n","tags":["a",`},
+		aisdk.TextStreamPart{Content: `"b"],"done":tr`},
+		aisdk.TextStreamPart{Content: `ue}`},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	)
+
+	var updates []map[string]any
+	for _, err := range stream.WithPartialJSON(func(partial map[string]any) {
+		updates = append(updates, partial)
+	}) {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []map[string]any{
+		{"title": "Hel"},
+		{"title": "Hello"},
+		{"title": "Hello\n", "tags": []any{"a"}},
+		{"title": "Hello\n", "tags": []any{"a", "b"}},
+		{"title": "Hello\n", "tags": []any{"a", "b"}, "done": true},
+	}, updates)
+}
+
+func TestMessage_TextContent(t *testing.T) {
+	t.Parallel()
+
+	message := aisdk.Message{
+		Role: "assistant",
+		Parts: []aisdk.Part{
+			{Type: aisdk.PartTypeStepStart},
+			{Type: aisdk.PartTypeReasoning, Reasoning: "The user wants "},
+			{Type: aisdk.PartTypeText, Text: "Let me check. "},
+			{Type: aisdk.PartTypeToolInvocation, ToolInvocation: &aisdk.ToolInvocation{ToolCallID: "tool_1", ToolName: "now"}},
+			{Type: aisdk.PartTypeStepStart},
+			{Type: aisdk.PartTypeReasoning, Reasoning: "the time."},
+			{Type: aisdk.PartTypeText, Text: "It's noon."},
+		},
+	}
+	require.Equal(t, "Let me check. It's noon.", message.TextContent())
+	require.Equal(t, "The user wants the time.", message.ReasoningContent())
+
+	require.Equal(t, "Hi", aisdk.Message{Role: "user", Content: "Hi"}.TextContent())
+}
+
+func TestWriteUIMessageStreamHeaders(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	aisdk.WriteUIMessageStreamHeaders(rec)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	require.Equal(t, "v1", rec.Header().Get("X-Vercel-AI-UI-Message-Stream"))
+	require.Empty(t, rec.Header().Get("X-Vercel-AI-Data-Stream"))
+}
+
+func TestStripReasoning(t *testing.T) {
+	t.Parallel()
+
+	messages := []aisdk.Message{
+		{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "What time is it?"}}},
+		{Role: "assistant", Parts: []aisdk.Part{
+			{Type: aisdk.PartTypeStepStart},
+			{Type: aisdk.PartTypeReasoning, Reasoning: "The user wants the time.", Details: []aisdk.ReasoningDetail{{Type: "text", Text: "The user wants the time.", Signature: "sig"}}},
+			{Type: aisdk.PartTypeText, Text: "It's noon."},
+		}},
+	}
+
+	stripped := aisdk.StripReasoning(messages)
+	require.Len(t, stripped, 2)
+	require.Equal(t, messages[0], stripped[0])
+	require.Equal(t, []aisdk.Part{
+		{Type: aisdk.PartTypeStepStart},
+		{Type: aisdk.PartTypeText, Text: "It's noon."},
+	}, stripped[1].Parts)
+
+	// The input must not be modified.
+	require.Len(t, messages[1].Parts, 3)
+	require.Equal(t, "The user wants the time.", messages[1].ReasoningContent())
+}
+
+func TestDataStream_WithToolCallStatus(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "sleep", Args: map[string]any{}},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+	).WithToolCalling(func(toolCall aisdk.ToolCall) any {
+		time.Sleep(10 * time.Millisecond)
+		return "done"
+	}, aisdk.WithToolCallStatus())
+
+	var statuses []map[string]any
+	var acc aisdk.DataStreamAccumulator
+	for part, err := range stream.WithAccumulator(&acc) {
+		require.NoError(t, err)
+		if p, ok := part.(aisdk.DataStreamDataPart); ok {
+			require.Len(t, p.Content, 1)
+			statuses = append(statuses, p.Content[0].(map[string]any))
+		}
+	}
+
+	require.Len(t, statuses, 2)
+	require.Equal(t, map[string]any{"type": "tool-status", "toolCallId": "tool_1", "status": "running"}, statuses[0])
+	require.Equal(t, "completed", statuses[1]["status"])
+	require.GreaterOrEqual(t, statuses[1]["durationMs"], int64(10))
+	require.Len(t, acc.Messages()[0].Annotations, 2)
+}
+
+func TestDataStreamAccumulator_PartialToolCall(t *testing.T) {
+	t.Parallel()
+
+	acc := aisdk.DataStreamAccumulator{PartialToolArgs: true}
+	push := func(part aisdk.DataStreamPart) {
+		require.NoError(t, acc.Push(part))
+	}
+	invocationOf := func(message aisdk.Message) *aisdk.ToolInvocation {
+		for _, part := range message.Parts {
+			if part.Type == aisdk.PartTypeToolInvocation {
+				return part.ToolInvocation
+			}
+		}
+		return nil
+	}
+	currentInvocation := func() *aisdk.ToolInvocation {
+		message, ok := acc.CurrentMessage()
+		require.True(t, ok)
+		return invocationOf(message)
+	}
+
+	push(aisdk.StartStepStreamPart{MessageID: "msg_1"})
+	push(aisdk.ToolCallStartStreamPart{ToolCallID: "tool_1", ToolName: "get_weather"})
+	// Parts appended after the start must not detach the tool call.
+	push(aisdk.TextStreamPart{Content: "Checking"})
+	push(aisdk.SourceStreamPart{SourceType: "url", ID: "1", URL: "https://example.com"})
+	push(aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `{"location":"San Fra`})
+
+	invocation := currentInvocation()
+	require.Equal(t, aisdk.ToolInvocationStatePartialCall, invocation.State)
+	require.Equal(t, map[string]any{"location": "San Fra"}, invocation.Args)
+
+	push(aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `ncisco"}`})
+	invocation = currentInvocation()
+	require.Equal(t, aisdk.ToolInvocationStatePartialCall, invocation.State)
+	require.Equal(t, map[string]any{"location": "San Francisco"}, invocation.Args)
+
+	// A tool call step keeps the message open for the step that follows.
+	push(aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls})
+	invocation = currentInvocation()
+	require.Equal(t, aisdk.ToolInvocationStateCall, invocation.State)
+
+	push(aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls})
+	_, ok := acc.CurrentMessage()
+	require.False(t, ok)
+
+	require.Len(t, acc.Messages(), 1)
+	invocation = invocationOf(acc.Messages()[0])
+	require.Equal(t, aisdk.ToolInvocationStateCall, invocation.State)
+	require.Equal(t, map[string]any{"location": "San Francisco"}, invocation.Args)
+}
+
+func TestDataStreamAccumulator_PartialToolArgsOff(t *testing.T) {
+	t.Parallel()
+
+	var acc aisdk.DataStreamAccumulator
+	require.NoError(t, acc.Push(aisdk.StartStepStreamPart{MessageID: "msg_1"}))
+	require.NoError(t, acc.Push(aisdk.ToolCallStartStreamPart{ToolCallID: "tool_1", ToolName: "get_weather"}))
+	require.NoError(t, acc.Push(aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `{"location":"San Fra`}))
+
+	invocationOf := func(message aisdk.Message) *aisdk.ToolInvocation {
+		return message.Parts[len(message.Parts)-1].ToolInvocation
+	}
+	message, ok := acc.CurrentMessage()
+	require.True(t, ok)
+	require.Equal(t, aisdk.ToolInvocationStatePartialCall, invocationOf(message).State)
+	require.Equal(t, map[string]any{}, invocationOf(message).Args)
+
+	require.NoError(t, acc.Push(aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `ncisco"}`}))
+	require.NoError(t, acc.Push(aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls}))
+	require.Len(t, acc.Messages(), 1)
+	require.Equal(t, map[string]any{"location": "San Francisco"}, invocationOf(acc.Messages()[0]).Args)
+}
+
+func BenchmarkDataStreamAccumulator_ToolCallDeltas(b *testing.B) {
+	// 64 KiB of args streamed in 16 byte deltas, like a model writing a file.
+	const deltas = 4096
+	delta := `"aaaaaaaaaaaaa",`
+	for _, partial := range []bool{false, true} {
+		b.Run(fmt.Sprintf("PartialToolArgs=%t", partial), func(b *testing.B) {
+			for range b.N {
+				acc := aisdk.DataStreamAccumulator{PartialToolArgs: partial}
+				_ = acc.Push(aisdk.StartStepStreamPart{MessageID: "msg_1"})
+				_ = acc.Push(aisdk.ToolCallStartStreamPart{ToolCallID: "tool_1", ToolName: "write_file"})
+				_ = acc.Push(aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `{"lines":[`})
+				for range deltas {
+					_ = acc.Push(aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: delta})
+				}
+				_ = acc.Push(aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `"a"]}`})
+				_ = acc.Push(aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls})
+			}
+		})
+	}
+}
+
+func TestDataStream_FilterAndMap(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ReasoningStreamPart{Content: "Thinking"},
+		aisdk.SourceStreamPart{SourceType: "url", ID: "1", URL: "http://example.com"},
+		aisdk.TextStreamPart{Content: "Hello"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	).Filter(func(part aisdk.DataStreamPart) bool {
+		_, ok := part.(aisdk.ReasoningStreamPart)
+		return !ok
+	}).Map(func(part aisdk.DataStreamPart) aisdk.DataStreamPart {
+		if p, ok := part.(aisdk.SourceStreamPart); ok {
+			p.URL = strings.Replace(p.URL, "http://", "https://", 1)
+			return p
+		}
+		return part
+	})
+
+	var parts []aisdk.DataStreamPart
+	for part, err := range stream {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+	require.Equal(t, []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.SourceStreamPart{SourceType: "url", ID: "1", URL: "https://example.com"},
+		aisdk.TextStreamPart{Content: "Hello"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	}, parts)
+}
+
+func TestDataStream_WithHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "query", Args: map[string]any{}},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+	).WithToolCalling(func(toolCall aisdk.ToolCall) any {
+		time.Sleep(50 * time.Millisecond)
+		return "rows"
+	}).WithHeartbeat(5 * time.Millisecond)
+
+	var out strings.Builder
+	err := stream.Pipe(&out)
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "2:[]\n")
+	require.True(t, strings.HasSuffix(out.String(), `a:{"toolCallId":"tool_1","result":"rows"}
+d:{"finishReason":"tool-calls","usage":{"promptTokens":null,"completionTokens":null}}
+`))
+}
+
+func TestTool_JSONSchema(t *testing.T) {
+	t.Parallel()
+
+	tool := aisdk.Tool{
+		Name: "get_weather",
+		Schema: aisdk.Schema{
+			Required: []string{"city"},
+			Properties: map[string]any{
+				"city": map[string]any{"type": "string"},
+			},
+		},
+	}
+	schema, err := tool.JSONSchema()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`, string(schema))
+
+	schema, err = aisdk.Tool{Name: "now"}.JSONSchema()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type":"object","properties":{}}`, string(schema))
+
+	_, err = aisdk.Tool{Name: "bad", Schema: aisdk.Schema{Properties: map[string]any{"f": func() {}}}}.JSONSchema()
+	require.ErrorContains(t, err, "failed to marshal schema of tool bad")
+}
+
+func TestDataStream_WithTimeout(t *testing.T) {
+	t.Parallel()
+
+	stalled := aisdk.DataStream(func(yield func(aisdk.DataStreamPart, error) bool) {
+		if !yield(aisdk.TextStreamPart{Content: "Hel"}, nil) {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+		yield(aisdk.TextStreamPart{Content: "lo"}, nil)
+	})
+
+	var parts []aisdk.DataStreamPart
+	var streamErr error
+	for part, err := range stalled.WithTimeout(20 * time.Millisecond) {
+		if err != nil {
+			streamErr = err
+			continue
+		}
+		parts = append(parts, part)
+	}
+	require.Equal(t, []aisdk.DataStreamPart{aisdk.TextStreamPart{Content: "Hel"}}, parts)
+	require.ErrorIs(t, streamErr, context.DeadlineExceeded)
+
+	fast := partsStream(aisdk.TextStreamPart{Content: "Hello"})
+	require.NoError(t, fast.WithTimeout(time.Second).Drain())
+}
+
+func TestPendingToolCalls(t *testing.T) {
+	t.Parallel()
+
+	message := aisdk.Message{Role: "assistant", Parts: []aisdk.Part{
+		{Type: aisdk.PartTypeText, Text: "Checking."},
+		{Type: aisdk.PartTypeToolInvocation, ToolInvocation: &aisdk.ToolInvocation{
+			State: aisdk.ToolInvocationStateResult, ToolCallID: "call_1", ToolName: "weather", Args: map[string]any{"city": "Paris"}, Result: "sunny",
+		}},
+		{Type: aisdk.PartTypeToolInvocation, ToolInvocation: &aisdk.ToolInvocation{
+			State: aisdk.ToolInvocationStateCall, ToolCallID: "call_2", ToolName: "weather", Args: map[string]any{"city": "Rome"},
+		}},
+		{Type: aisdk.PartTypeToolInvocation, ToolInvocation: &aisdk.ToolInvocation{
+			State: aisdk.ToolInvocationStatePartialCall, ToolCallID: "call_3", ToolName: "weather",
+		}},
+	}}
+	require.Equal(t, []aisdk.ToolCall{{
+		ID:   "call_2",
+		Name: "weather",
+		Args: map[string]any{"city": "Rome"},
+	}}, aisdk.PendingToolCalls(message))
+	require.Empty(t, aisdk.PendingToolCalls(aisdk.Message{Role: "assistant", Content: "Hi"}))
+}
+
+func TestEnsureSystemMessage(t *testing.T) {
+	t.Parallel()
+
+	messages := []aisdk.Message{{Role: "user", Content: "Hi"}}
+
+	once := aisdk.EnsureSystemMessage(messages, "You are a helpful assistant.")
+	require.Len(t, once, 2)
+	require.Equal(t, "system", once[0].Role)
+	require.Equal(t, "You are a helpful assistant.", once[0].TextContent())
+	require.Len(t, messages, 1)
+
+	twice := aisdk.EnsureSystemMessage(once, "You are a helpful assistant.")
+	require.Equal(t, once, twice)
+}
+
+func TestDataStream_WithUsageCallback(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "Hello"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop, Usage: aisdk.Usage{PromptTokens: int64Ptr(1), CompletionTokens: int64Ptr(1)}},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop, Usage: aisdk.Usage{PromptTokens: int64Ptr(10), CompletionTokens: int64Ptr(5)}},
+	)
+
+	var calls int
+	var usage aisdk.Usage
+	var finishReason aisdk.FinishReason
+	var count int
+	for _, err := range stream.WithUsageCallback(func(u aisdk.Usage, reason aisdk.FinishReason) {
+		calls++
+		usage = u
+		finishReason = reason
+	}) {
+		require.NoError(t, err)
+		count++
+	}
+
+	require.Equal(t, 1, calls)
+	require.Equal(t, int64(10), usage.PromptTokensOrZero())
+	require.Equal(t, int64(5), *usage.CompletionTokens)
+	require.Equal(t, aisdk.FinishReasonStop, finishReason)
+	require.Equal(t, 4, count)
+}
+
+func TestDataStream_WithFinish(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "Hello"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop, Usage: aisdk.Usage{PromptTokens: int64Ptr(10), CompletionTokens: int64Ptr(5)}},
+	)
+
+	var calls int
+	var messages []aisdk.Message
+	var finishReason aisdk.FinishReason
+	var usage aisdk.Usage
+	var count int
+	for _, err := range stream.WithFinish(func(m []aisdk.Message, reason aisdk.FinishReason, u aisdk.Usage) {
+		calls++
+		messages = m
+		finishReason = reason
+		usage = u
+	}) {
+		require.NoError(t, err)
+		count++
+	}
+
+	require.Equal(t, 4, count)
+	require.Equal(t, 1, calls)
+	require.Len(t, messages, 1)
+	require.Equal(t, "Hello", messages[0].Content)
+	require.Equal(t, aisdk.FinishReasonStop, finishReason)
+	require.Equal(t, int64(10), usage.PromptTokensOrZero())
+
+	// Stopping early or failing skips the callback.
+	calls = 0
+	for range stream.WithFinish(func([]aisdk.Message, aisdk.FinishReason, aisdk.Usage) { calls++ }) {
+		break
+	}
+	failing := partsStream(aisdk.ErrorStreamPart{Content: "overloaded"})
+	require.Error(t, failing.WithFinish(func([]aisdk.Message, aisdk.FinishReason, aisdk.Usage) { calls++ }).Drain())
+	require.Zero(t, calls)
+}
+
+func TestDataStream_WithToolCallingProgress(t *testing.T) {
+	t.Parallel()
+
+	var late func(string)
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "report", Args: map[string]any{}},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+	).WithToolCallingProgress(func(toolCall aisdk.ToolCall, progress func(string)) any {
+		progress("Authenticating")
+		progress("Fetching")
+		late = progress
+		return "done"
+	})
+
+	var acc aisdk.DataStreamAccumulator
+	var out strings.Builder
+	require.NoError(t, stream.WithAccumulator(&acc).Pipe(&out))
+	require.Contains(t, out.String(), `8:[{"status":"Authenticating","toolCallId":"tool_1","type":"tool-progress"}]
+8:[{"status":"Fetching","toolCallId":"tool_1","type":"tool-progress"}]
+a:{"toolCallId":"tool_1","result":"done"}
+`)
+	require.Equal(t, []any{
+		map[string]any{"type": "tool-progress", "toolCallId": "tool_1", "status": "Authenticating"},
+		map[string]any{"type": "tool-progress", "toolCallId": "tool_1", "status": "Fetching"},
+	}, acc.Messages()[0].Annotations)
+
+	// Progress after the handler returned is ignored.
+	late("Too late")
+}
+
+func TestDataStreamAccumulator_GenerateID(t *testing.T) {
+	t.Parallel()
+
+	ids := 0
+	acc := aisdk.DataStreamAccumulator{GenerateID: func() string {
+		ids++
+		return fmt.Sprintf("gen_%d", ids)
+	}}
+	stream := partsStream(
+		aisdk.StartStepStreamPart{},
+		aisdk.TextStreamPart{Content: "Hello"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.StartStepStreamPart{MessageID: "msg_provider"},
+		aisdk.TextStreamPart{Content: "Again"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	)
+	for part, err := range stream {
+		require.NoError(t, err)
+		require.NoError(t, acc.Push(part))
+		if current, ok := acc.CurrentMessage(); ok {
+			// The ID is stable while the message streams.
+			require.NotEqual(t, "gen_2", current.ID)
+		}
+	}
+
+	messages := acc.Messages()
+	require.Len(t, messages, 2)
+	require.Equal(t, "gen_1", messages[0].ID)
+	require.Equal(t, "msg_provider", messages[1].ID)
+	require.Equal(t, "gen_1", acc.Steps()[0].Message.ID)
+}
+
+func TestDataStream_Pipeline(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ReasoningStreamPart{Content: "Let me look."},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "now", Args: map[string]any{}},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+	)
+
+	// The stages are passed in the wrong order.
+	var acc aisdk.DataStreamAccumulator
+	require.NoError(t, stream.Pipeline(
+		aisdk.AccumulatorStage(&acc),
+		aisdk.ToolCallingStage(func(toolCall aisdk.ToolCall) any {
+			return "noon"
+		}),
+		aisdk.MiddlewareStage(aisdk.DataStream.WithoutReasoning),
+	).Drain())
+
+	parts := acc.Messages()[0].Parts
+	require.Len(t, parts, 2)
+	require.Equal(t, aisdk.PartTypeStepStart, parts[0].Type)
+	require.Equal(t, aisdk.ToolInvocationStateResult, parts[1].ToolInvocation.State)
+	require.Equal(t, "noon", parts[1].ToolInvocation.Result)
+}
+
+func TestDataStream_WithToolCallingError(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "get_weather", Args: map[string]any{"location": "Paris"}},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+	).WithToolCalling(func(toolCall aisdk.ToolCall) any {
+		return errors.New("weather service unavailable")
+	})
+
+	var acc aisdk.DataStreamAccumulator
+	var result aisdk.ToolResultStreamPart
+	for part, err := range stream.WithAccumulator(&acc) {
+		require.NoError(t, err)
+		if p, ok := part.(aisdk.ToolResultStreamPart); ok {
+			result = p
+		}
+	}
+	require.True(t, result.IsError)
+	require.Equal(t, "weather service unavailable", result.Result)
+
+	formatted, err := result.Format()
+	require.NoError(t, err)
+	require.Equal(t, `a:{"toolCallId":"tool_1","result":"weather service unavailable","isError":true}`+"\n", formatted)
+
+	invocation := acc.Messages()[0].Parts[1].ToolInvocation
+	require.Equal(t, aisdk.ToolInvocationStateResult, invocation.State)
+	require.True(t, invocation.IsError)
+}
+
+func TestDataStream_WithoutReasoning(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ReasoningStreamPart{Content: "The user greeted me."},
+		aisdk.ReasoningSignatureStreamPart{Signature: "sig"},
+		aisdk.RedactedReasoningStreamPart{Data: "redacted"},
+		aisdk.TextStreamPart{Content: "Hello!"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	)
+
+	var acc aisdk.DataStreamAccumulator
+	var parts []aisdk.DataStreamPart
+	for part, err := range stream.WithAccumulator(&acc).WithoutReasoning() {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+
+	require.Len(t, parts, 4)
+	require.Equal(t, aisdk.TextStreamPart{Content: "Hello!"}, parts[1])
+	require.Equal(t, "The user greeted me.", acc.Messages()[0].ReasoningContent())
+}
+
+func TestDataStream_Pipe_WithoutHTMLEscaping(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.TextStreamPart{Content: "if a < b && b > c {}"},
+		aisdk.TextStreamPart{Content: `a literal \u003c`},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "run", Args: map[string]any{"code": "<b>"}},
+	)
+
+	var escaped strings.Builder
+	require.NoError(t, stream.Pipe(&escaped))
+	require.Equal(t, `0:"if a \u003c b \u0026\u0026 b \u003e c {}"
+0:"a literal \\u003c"
+9:{"toolCallId":"tool_1","toolName":"run","args":{"code":"\u003cb\u003e"}}
+`, escaped.String())
+
+	var raw strings.Builder
+	require.NoError(t, stream.Pipe(&raw, aisdk.WithoutHTMLEscaping()))
+	require.Equal(t, `0:"if a < b && b > c {}"
+0:"a literal \\u003c"
+9:{"toolCallId":"tool_1","toolName":"run","args":{"code":"<b>"}}
+`, raw.String())
+
+	// The raw output is still valid JSON for the same text.
+	for i, line := range strings.Split(strings.TrimSuffix(raw.String(), "\n"), "\n")[:2] {
+		var content string
+		require.NoError(t, json.Unmarshal([]byte(line[2:]), &content))
+		require.Equal(t, []string{"if a < b && b > c {}", `a literal \u003c`}[i], content)
+	}
+}
+
+func TestDataStream_WithLogger(t *testing.T) {
+	t.Parallel()
+
+	var logs strings.Builder
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})).With("requestId", "req_1")
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "get_weather", Args: map[string]any{}},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls, Usage: aisdk.Usage{PromptTokens: int64Ptr(10), CompletionTokens: int64Ptr(5)}},
+	)
+	var count int
+	for _, err := range stream.WithLogger(logger) {
+		require.NoError(t, err)
+		count++
+	}
+	require.Equal(t, 4, count)
+
+	require.Equal(t, `level=DEBUG msg="data stream part" requestId=req_1 type=aisdk.StartStepStreamPart
+level=INFO msg="tool call" requestId=req_1 toolCallId=tool_1 toolName=get_weather
+level=DEBUG msg="data stream part" requestId=req_1 type=aisdk.FinishStepStreamPart
+level=INFO msg="data stream finished" requestId=req_1 finishReason=tool-calls promptTokens=10 completionTokens=5
+`, logs.String())
+
+	// A nil logger passes the stream through.
+	count = 0
+	for _, err := range stream.WithLogger(nil) {
+		require.NoError(t, err)
+		count++
+	}
+	require.Equal(t, 4, count)
+}
+
+func TestPrefill(t *testing.T) {
+	t.Parallel()
+
+	require.Empty(t, aisdk.Prefill(nil))
+	require.Empty(t, aisdk.Prefill([]aisdk.Message{{Role: "user", Content: "Hi"}}))
+	require.Equal(t, "Sure:", aisdk.Prefill([]aisdk.Message{
+		{Role: "user", Content: "Hi"},
+		{Role: "assistant", Content: "Sure:"},
+	}))
+}
+
+func TestDataStream_WithTokenCounter(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ReasoningStreamPart{Content: "Think"},
+		aisdk.TextStreamPart{Content: "Hel"},
+		aisdk.TextStreamPart{Content: "lo, world"},
+		aisdk.ToolCallStartStreamPart{ToolCallID: "tool_1", ToolName: "search"},
+		aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `{"q":"go"}`},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+	)
+
+	var updates []int
+	var count int
+	for _, err := range stream.WithTokenCounter("gpt-4o", func(completionTokens int) {
+		updates = append(updates, completionTokens)
+	}) {
+		require.NoError(t, err)
+		count++
+	}
+	require.Equal(t, 7, count)
+	// 5, 8, 17 and 27 characters at 4 characters per token.
+	require.Equal(t, []int{2, 5, 7}, updates)
+
+	updates = nil
+	require.NoError(t, stream.WithTokenCounter("claude-sonnet-4-20250514", func(completionTokens int) {
+		updates = append(updates, completionTokens)
+	}).Drain())
+	require.Equal(t, 8, updates[len(updates)-1])
+}
+
+func TestDataStream_WithMaxTokens(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ReasoningStreamPart{Content: "Hmm."},
+		aisdk.TextStreamPart{Content: "Hello, "},
+		aisdk.TextStreamPart{Content: "wonderful world!"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	)
+
+	// 4 tokens at 4 characters per token allow 16 characters.
+	var parts []aisdk.DataStreamPart
+	for part, err := range stream.WithMaxTokens(4, "gpt-4o") {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+	require.Equal(t, []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ReasoningStreamPart{Content: "Hmm."},
+		aisdk.TextStreamPart{Content: "Hello, "},
+		aisdk.TextStreamPart{Content: "wonde"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonLength},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonLength},
+	}, parts)
+
+	var acc aisdk.DataStreamAccumulator
+	require.NoError(t, stream.WithMaxTokens(100, "gpt-4o").WithAccumulator(&acc).Drain())
+	require.Equal(t, aisdk.FinishReasonStop, acc.FinishReason())
+}
+
+func TestDataStream_StopOn(t *testing.T) {
+	t.Parallel()
+
+	pulled := 0
+	stream := aisdk.DataStream(func(yield func(aisdk.DataStreamPart, error) bool) {
+		for _, part := range []aisdk.DataStreamPart{
+			aisdk.StartStepStreamPart{MessageID: "msg_1"},
+			aisdk.TextStreamPart{Content: "Answer: 42<"},
+			aisdk.TextStreamPart{Content: "/ans"},
+			aisdk.TextStreamPart{Content: "wer> and more"},
+			aisdk.TextStreamPart{Content: "never pulled"},
+		} {
+			pulled++
+			if !yield(part, nil) {
+				return
+			}
+		}
+	})
+
+	var parts []aisdk.DataStreamPart
+	for part, err := range stream.StopOn("</answer>") {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+	require.Equal(t, []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "Answer: 42"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	}, parts)
+	require.Equal(t, 4, pulled)
+
+	// Held back text that turns out not to be the sequence is flushed.
+	parts = nil
+	for part, err := range partsStream(
+		aisdk.TextStreamPart{Content: "a </an"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	).StopOn("</answer>") {
+		require.NoError(t, err)
+		parts = append(parts, part)
+	}
+	require.Equal(t, []aisdk.DataStreamPart{
+		aisdk.TextStreamPart{Content: "a "},
+		aisdk.TextStreamPart{Content: "</an"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	}, parts)
+}
+
+func TestDataStream_MergeStepStarts(t *testing.T) {
+	t.Parallel()
+
+	parts := []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.DataStreamDataPart{Content: []any{}},
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "Hello"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonLength, IsContinued: true},
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "!"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	}
+
+	var merged []aisdk.DataStreamPart
+	for part, err := range partsStream(parts...).MergeStepStarts() {
+		require.NoError(t, err)
+		merged = append(merged, part)
+	}
+	require.Len(t, merged, 8)
+	require.Equal(t, aisdk.DataStreamDataPart{Content: []any{}}, merged[1])
+
+	// The accumulator merges back-to-back step starts by itself.
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range partsStream(parts...).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+	var types []aisdk.PartType
+	for _, part := range acc.Messages()[0].Parts {
+		types = append(types, part.Type)
+	}
+	require.Equal(t, []aisdk.PartType{aisdk.PartTypeStepStart, aisdk.PartTypeText, aisdk.PartTypeStepStart, aisdk.PartTypeText}, types)
+}
+
+func TestDataStream_WithRateLimit(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "a"},
+		aisdk.TextStreamPart{Content: "b"},
+		aisdk.TextStreamPart{Content: "c"},
+		aisdk.TextStreamPart{Content: "d"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	)
+
+	start := time.Now()
+	var times []time.Duration
+	for _, err := range stream.WithRateLimit(50) {
+		require.NoError(t, err)
+		times = append(times, time.Since(start))
+	}
+	require.Len(t, times, 7)
+
+	// The first part is sent right away and each of the other four waits
+	// 20ms, while the finish parts aren't limited.
+	require.Less(t, times[0], 10*time.Millisecond)
+	require.GreaterOrEqual(t, times[4], 80*time.Millisecond)
+	require.Less(t, times[6]-times[4], 10*time.Millisecond)
+}
+
+func TestDataStreamAccumulator_SignatureWithoutReasoning(t *testing.T) {
+	t.Parallel()
+
+	// Anthropic signs empty thinking blocks, and redacted ones may be
+	// followed by a signature.
+	var acc aisdk.DataStreamAccumulator
+	require.NoError(t, partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ReasoningSignatureStreamPart{Signature: "sig_1"},
+		aisdk.RedactedReasoningStreamPart{Data: "redacted"},
+		aisdk.ReasoningSignatureStreamPart{Signature: "sig_2"},
+		aisdk.TextStreamPart{Content: "Hello!"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	).WithAccumulator(&acc).Drain())
+
+	message := acc.Messages()[0]
+	require.Equal(t, aisdk.PartTypeReasoning, message.Parts[1].Type)
+	require.Equal(t, []aisdk.ReasoningDetail{
+		{Type: "text", Signature: "sig_1"},
+		{Type: "redacted", Data: "redacted"},
+		{Type: "text", Signature: "sig_2"},
+	}, message.Parts[1].Details)
+	require.Equal(t, "Hello!", message.TextContent())
+}
+
+func TestDataStreamAccumulator_InterleavedReasoning(t *testing.T) {
+	t.Parallel()
+
+	var acc aisdk.DataStreamAccumulator
+	for _, err := range partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ReasoningStreamPart{Content: "First, "},
+		aisdk.ReasoningStreamPart{Content: "greet."},
+		aisdk.ReasoningSignatureStreamPart{Signature: "sig_1"},
+		aisdk.TextStreamPart{Content: "Hello!"},
+		aisdk.ReasoningStreamPart{Content: "Then, ask."},
+		aisdk.ReasoningSignatureStreamPart{Signature: "sig_2"},
+		aisdk.TextStreamPart{Content: " How are you?"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	).WithAccumulator(&acc) {
+		require.NoError(t, err)
+	}
+
+	message := acc.Messages()[0]
+	var types []aisdk.PartType
+	for _, part := range message.Parts {
+		types = append(types, part.Type)
+	}
+	require.Equal(t, []aisdk.PartType{
+		aisdk.PartTypeStepStart,
+		aisdk.PartTypeReasoning,
+		aisdk.PartTypeText,
+		aisdk.PartTypeReasoning,
+		aisdk.PartTypeText,
+	}, types)
+	require.Equal(t, "First, greet.", message.Parts[1].Reasoning)
+	require.Equal(t, []aisdk.ReasoningDetail{{Type: "text", Text: "First, greet.", Signature: "sig_1"}}, message.Parts[1].Details)
+	require.Equal(t, "Then, ask.", message.Parts[3].Reasoning)
+	require.Equal(t, "sig_2", message.Parts[3].Details[0].Signature)
+	require.Equal(t, "First, greet.Then, ask.", message.ReasoningContent())
+	require.Equal(t, "Hello! How are you?", message.Content)
+}
+
+func TestDataStream_ToolCallNumbers(t *testing.T) {
+	t.Parallel()
+
+	stream := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStartStreamPart{ToolCallID: "tool_1", ToolName: "get_order"},
+		aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: `{"id": 9007199254740993,`},
+		aisdk.ToolCallDeltaStreamPart{ToolCallID: "tool_1", ArgsTextDelta: ` "price": 1.5}`},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+	)
+
+	var args map[string]any
+	for _, err := range stream.WithToolCalling(func(toolCall aisdk.ToolCall) any {
+		args = toolCall.Args
+		return "shipped"
+	}, aisdk.WithToolCallNumbers()) {
+		require.NoError(t, err)
+	}
+	require.Equal(t, map[string]any{"id": json.Number("9007199254740993"), "price": json.Number("1.5")}, args)
+
+	// Integers beyond float64 precision are sent back unchanged.
+	accumulated := aisdk.DataStreamAccumulator{UseNumber: true}
+	for _, err := range stream.WithAccumulator(&accumulated) {
+		require.NoError(t, err)
+	}
+	invocation := accumulated.Messages()[0].Parts[1].ToolInvocation
+	data, err := json.Marshal(invocation.Args)
+	require.NoError(t, err)
+	require.Equal(t, `{"id":9007199254740993,"price":1.5}`, string(data))
+}
+
+func TestDataStreamAccumulator_Steps(t *testing.T) {
+	t.Parallel()
+
+	var acc aisdk.DataStreamAccumulator
+	for _, part := range []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "Let me search."},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "search", Args: map[string]any{"query": "weather"}},
+		aisdk.ToolResultStreamPart{ToolCallID: "tool_1", Result: "sunny"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonToolCalls, Usage: aisdk.Usage{PromptTokens: int64Ptr(10), CompletionTokens: int64Ptr(5)}, IsContinued: true},
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "It's sunny."},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop, Usage: aisdk.Usage{PromptTokens: int64Ptr(20), CompletionTokens: int64Ptr(3)}},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop, Usage: aisdk.Usage{PromptTokens: int64Ptr(30), CompletionTokens: int64Ptr(8)}},
+	} {
+		require.NoError(t, acc.Push(part))
+	}
+
+	require.Len(t, acc.Messages(), 1)
+	steps := acc.Steps()
+	require.Len(t, steps, 2)
+
+	require.Equal(t, aisdk.FinishReasonToolCalls, steps[0].FinishReason)
+	require.Equal(t, int64(10), steps[0].Usage.PromptTokensOrZero())
+	require.Equal(t, "msg_1", steps[0].Message.ID)
+	require.Equal(t, "Let me search.", steps[0].Message.Content)
+	require.Len(t, steps[0].Message.Parts, 3)
+	require.Equal(t, aisdk.PartTypeStepStart, steps[0].Message.Parts[0].Type)
+	require.Equal(t, "sunny", steps[0].Message.Parts[2].ToolInvocation.Result)
+
+	require.Equal(t, aisdk.FinishReasonStop, steps[1].FinishReason)
+	require.Equal(t, int64(3), steps[1].Usage.CompletionTokensOrZero())
+	require.Equal(t, "It's sunny.", steps[1].Message.Content)
+	require.Len(t, steps[1].Message.Parts, 2)
+
+	// A stream that ends without finishing its step still has the step.
+	var unfinished aisdk.DataStreamAccumulator
+	for _, part := range []aisdk.DataStreamPart{
+		aisdk.StartStepStreamPart{MessageID: "msg_2"},
+		aisdk.TextStreamPart{Content: "Cut"},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonError},
+	} {
+		require.NoError(t, unfinished.Push(part))
+	}
+	require.Len(t, unfinished.Steps(), 1)
+	require.Equal(t, aisdk.FinishReasonError, unfinished.Steps()[0].FinishReason)
+}
+
+func TestDataStream_Drain(t *testing.T) {
+	t.Parallel()
+
+	var acc aisdk.DataStreamAccumulator
+	require.NoError(t, partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.TextStreamPart{Content: "Hello"},
+		aisdk.FinishStepStreamPart{FinishReason: aisdk.FinishReasonStop},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonStop},
+	).WithAccumulator(&acc).Drain())
+	require.Equal(t, "Hello", acc.Messages()[0].Content)
+
+	var after bool
+	failing := aisdk.DataStream(func(yield func(aisdk.DataStreamPart, error) bool) {
+		if !yield(nil, errors.New("connection reset")) {
+			return
+		}
+		after = true
+	})
+	require.EqualError(t, failing.Drain(), "connection reset")
+	require.False(t, after)
+}
+
+func TestDataStream_ToolCallDeltaByIndex(t *testing.T) {
+	t.Parallel()
+
+	// Deltas without an ID belong to the call started with their index.
+	parts := func() aisdk.DataStream {
+		return partsStream(
+			aisdk.StartStepStreamPart{MessageID: "msg_1"},
+			aisdk.ToolCallStartStreamPart{ToolCallID: "tool_1", ToolName: "time"},
+			aisdk.ToolCallStartStreamPart{ToolCallID: "tool_2", ToolName: "weather", Index: 1},
+			aisdk.ToolCallDeltaStreamPart{ArgsTextDelta: `{"city":"Paris"}`, Index: 1},
+			aisdk.ToolCallDeltaStreamPart{ArgsTextDelta: `{}`},
+			aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+		)
+	}
+
+	calls := make(map[string]map[string]any)
+	require.NoError(t, parts().WithToolCalling(func(toolCall aisdk.ToolCall) any {
+		calls[toolCall.ID] = toolCall.Args
+		return "done"
+	}).Drain())
+	require.Equal(t, map[string]map[string]any{
+		"tool_1": {},
+		"tool_2": {"city": "Paris"},
+	}, calls)
+
+	var batched []aisdk.ToolCallStreamPart
+	for part, err := range parts().WithBatchedToolCalls() {
+		require.NoError(t, err)
+		if p, ok := part.(aisdk.ToolCallStreamPart); ok {
+			batched = append(batched, p)
+		}
+	}
+	require.Len(t, batched, 2)
+
+	var acc aisdk.DataStreamAccumulator
+	require.NoError(t, parts().WithAccumulator(&acc).Drain())
+	var args []any
+	for _, part := range acc.Messages()[0].Parts {
+		if part.ToolInvocation != nil {
+			args = append(args, part.ToolInvocation.Args)
+		}
+	}
+	require.Equal(t, []any{map[string]any{}, map[string]any{"city": "Paris"}}, args)
+}
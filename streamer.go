@@ -0,0 +1,259 @@
+package aisdk
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/openai/openai-go"
+)
+
+// StreamRequest is a chat request that any Streamer can send.
+type StreamRequest struct {
+	Model    string
+	Messages []Message
+	Tools    []Tool
+	// MaxTokens limits the tokens of the response. Zero uses the provider's
+	// default, or 4096 for Anthropic, which requires a limit. See
+	// WithAnthropicBetas to raise Anthropic's upper limit.
+	MaxTokens int64
+	// Thinking is the reasoning budget. Zero disables reasoning. It's dropped
+	// for models that Capabilities knows not to support reasoning, instead
+	// of letting the provider reject the request.
+	Thinking ThinkingBudget
+	// StrictThinking returns an error for a Thinking budget that the model
+	// doesn't support, instead of dropping it.
+	StrictThinking bool
+	// StopSequences end the response when the model generates one of them.
+	// OpenAI accepts at most 4. See StopOn to stop on a sequence locally.
+	StopSequences []string
+	// Prediction is content the response is expected to largely repeat, like
+	// a file being edited, sent to OpenAI as a predicted output to cut latency.
+	// Other providers ignore it.
+	Prediction string
+	// ProviderExtras are raw request parameters by provider, named like in
+	// ProviderError, for features StreamRequest doesn't model, e.g.
+	// {"openai": {"seed": 42}} or {"anthropic": {"metadata": {"user_id": "u_1"}}}.
+	// They're merged into the request body and override fields of the same name.
+	ProviderExtras map[string]map[string]any
+}
+
+// Streamer streams responses of a provider, so that callers can switch
+// providers without changing how they build requests. Conversion errors are
+// returned right away, and errors of the request are yielded by the stream.
+type Streamer interface {
+	Stream(ctx context.Context, req StreamRequest) (DataStream, error)
+}
+
+const (
+	// anthropicDefaultMaxTokens is the max tokens of requests that don't set one.
+	anthropicDefaultMaxTokens = 4096
+	// anthropicMaxTokens is the largest max tokens Anthropic accepts.
+	anthropicMaxTokens = 64_000
+	// anthropicExtendedMaxTokens is the largest max tokens Anthropic accepts
+	// with the extended output beta.
+	anthropicExtendedMaxTokens = 128_000
+)
+
+// AnthropicStreamerOption configures NewAnthropicStreamer.
+type AnthropicStreamerOption func(*anthropicStreamer)
+
+// WithAnthropicBetas enables beta features for every request, sent in the
+// anthropic-beta header next to any betas set on the client. With
+// anthropic.AnthropicBetaOutput128k2025_02_19 requests may set up to 128000
+// max tokens instead of 64000.
+func WithAnthropicBetas(betas ...anthropic.AnthropicBeta) AnthropicStreamerOption {
+	return func(s *anthropicStreamer) {
+		s.betas = append(s.betas, betas...)
+	}
+}
+
+type anthropicStreamer struct {
+	client anthropic.Client
+	betas  []anthropic.AnthropicBeta
+}
+
+// NewAnthropicStreamer returns a Streamer for Anthropic. The client is used as
+// is, so configure its HTTP client, API key and base URL when creating it.
+func NewAnthropicStreamer(client anthropic.Client, opts ...AnthropicStreamerOption) Streamer {
+	s := &anthropicStreamer{client: client}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *anthropicStreamer) Stream(ctx context.Context, req StreamRequest) (DataStream, error) {
+	messages, system, err := MessagesToAnthropic(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	limit := int64(anthropicMaxTokens)
+	if slices.Contains(s.betas, anthropic.AnthropicBetaOutput128k2025_02_19) {
+		limit = anthropicExtendedMaxTokens
+	}
+	if maxTokens > limit {
+		return nil, fmt.Errorf("max tokens of %d exceeds Anthropic's limit of %d", maxTokens, limit)
+	}
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.Model(req.Model),
+		MaxTokens: maxTokens,
+		Messages:  messages,
+		System:    system,
+	}
+	if len(req.Tools) > 0 {
+		params.Tools = ToolsToAnthropic(req.Tools)
+	}
+	if len(req.StopSequences) > 0 {
+		params.StopSequences = req.StopSequences
+	}
+	thinking, err := supportedThinking("anthropic", req)
+	if err != nil {
+		return nil, err
+	}
+	if thinking > 0 {
+		params.Thinking, err = ThinkingBudgetToAnthropic(thinking, maxTokens)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if extras := req.ProviderExtras["anthropic"]; len(extras) > 0 {
+		params.SetExtraFields(extras)
+	}
+	var opts []anthropicoption.RequestOption
+	if len(s.betas) > 0 {
+		betas := make([]string, len(s.betas))
+		for i, beta := range s.betas {
+			betas[i] = string(beta)
+		}
+		opts = append(opts, anthropicoption.WithHeaderAdd("anthropic-beta", strings.Join(betas, ",")))
+	}
+	return AnthropicToDataStream(s.client.Messages.NewStreaming(ctx, params, opts...)), nil
+}
+
+// openAIMaxStopSequences is the most stop sequences OpenAI accepts.
+const openAIMaxStopSequences = 4
+
+type openAIStreamer struct {
+	client openai.Client
+}
+
+// NewOpenAIStreamer returns a Streamer for OpenAI. The client is used as is,
+// so configure its HTTP client, API key and base URL when creating it.
+//
+// OpenAI has no reasoning budget, so the thinking budget of a request selects
+// the reasoning effort: low below 4096 tokens, medium below 16384 and high
+// above.
+func NewOpenAIStreamer(client openai.Client) Streamer {
+	return &openAIStreamer{client: client}
+}
+
+func (s *openAIStreamer) Stream(ctx context.Context, req StreamRequest) (DataStream, error) {
+	messages, err := MessagesToOpenAI(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.StopSequences) > openAIMaxStopSequences {
+		return nil, fmt.Errorf("%d stop sequences exceed OpenAI's limit of %d", len(req.StopSequences), openAIMaxStopSequences)
+	}
+	params := openai.ChatCompletionNewParams{
+		Model:    openai.ChatModel(req.Model),
+		Messages: messages,
+		StreamOptions: openai.ChatCompletionStreamOptionsParam{
+			IncludeUsage: openai.Bool(true),
+		},
+	}
+	if len(req.Tools) > 0 {
+		params.Tools = ToolsToOpenAI(req.Tools)
+	}
+	if req.MaxTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(req.MaxTokens)
+	}
+	if len(req.StopSequences) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{
+			OfStringArray: req.StopSequences,
+		}
+	}
+	thinking, err := supportedThinking("openai", req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case thinking <= 0:
+	case thinking < 4096:
+		params.ReasoningEffort = openai.ReasoningEffortLow
+	case thinking < 16384:
+		params.ReasoningEffort = openai.ReasoningEffortMedium
+	default:
+		params.ReasoningEffort = openai.ReasoningEffortHigh
+	}
+	if req.Prediction != "" {
+		params.Prediction = openai.ChatCompletionPredictionContentParam{
+			Content: openai.ChatCompletionPredictionContentContentUnionParam{
+				OfString: openai.String(req.Prediction),
+			},
+		}
+	}
+	if extras := req.ProviderExtras["openai"]; len(extras) > 0 {
+		params.SetExtraFields(extras)
+	}
+	return OpenAIToDataStream(s.client.Chat.Completions.NewStreaming(ctx, params)), nil
+}
+
+// supportedThinking returns the thinking budget of the request, or zero if
+// the model is known not to support reasoning. Unknown models are left to
+// the provider.
+func supportedThinking(provider string, req StreamRequest) (ThinkingBudget, error) {
+	if req.Thinking <= 0 {
+		return 0, nil
+	}
+	capabilities := Capabilities(provider, req.Model)
+	if capabilities.Reasoning || capabilities == (ProviderCapabilities{}) {
+		return req.Thinking, nil
+	}
+	if req.StrictThinking {
+		return 0, fmt.Errorf("model %s does not support reasoning", req.Model)
+	}
+	return 0, nil
+}
+
+// StreamConfig configures StreamChat.
+type StreamConfig struct {
+	// Streamer sends the request to the provider, e.g. NewOpenAIStreamer.
+	Streamer Streamer
+	Model    string
+	Tools    []Tool
+	// HandleToolCall runs the tool calls of the response, like the handler
+	// of WithToolCalling. Without it, tool calls are left to the client.
+	HandleToolCall func(toolCall ToolCall) any
+}
+
+// StreamChat streams the response to a chat decoded from `useChat`, with tool
+// calls handled by cfg.HandleToolCall. The returned accumulator holds the
+// response once the stream is consumed, e.g. by Pipe, to store the chat.
+// For settings like MaxTokens or Thinking, call a Streamer directly.
+func StreamChat(ctx context.Context, chat Chat, cfg StreamConfig) (DataStream, *DataStreamAccumulator, error) {
+	if cfg.Streamer == nil {
+		return nil, nil, fmt.Errorf("stream config has no streamer")
+	}
+	stream, err := cfg.Streamer.Stream(ctx, StreamRequest{
+		Model:    cfg.Model,
+		Messages: chat.Messages,
+		Tools:    cfg.Tools,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.HandleToolCall != nil {
+		stream = stream.WithToolCalling(cfg.HandleToolCall)
+	}
+	acc := &DataStreamAccumulator{}
+	return stream.WithAccumulator(acc), acc, nil
+}
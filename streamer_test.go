@@ -0,0 +1,275 @@
+package aisdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/morecommits/aisdk-go"
+	"github.com/openai/openai-go"
+	openaioption "github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/require"
+)
+
+// headerTransport sets a header on every request, standing in for a custom
+// transport like mTLS or tracing.
+type headerTransport struct{}
+
+func (headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("X-Transport", "custom")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// streamerRequest is a request recorded by newStreamerServer.
+type streamerRequest struct {
+	Header http.Header
+	Body   map[string]any
+}
+
+// newStreamerServer returns a server that requires the custom transport and
+// records the request.
+func newStreamerServer(t *testing.T, body string, request *streamerRequest) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Transport") != "custom" {
+			http.Error(w, "missing transport header", http.StatusBadRequest)
+			return
+		}
+		request.Header = r.Header.Clone()
+		if err := json.NewDecoder(r.Body).Decode(&request.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+const anthropicStreamerBody = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello!"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":2}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestAnthropicStreamer(t *testing.T) {
+	t.Parallel()
+
+	var request streamerRequest
+	server := newStreamerServer(t, anthropicStreamerBody, &request)
+
+	client := anthropic.NewClient(
+		anthropicoption.WithBaseURL(server.URL),
+		anthropicoption.WithAPIKey("sk-test"),
+		anthropicoption.WithHTTPClient(&http.Client{Transport: headerTransport{}}),
+	)
+	var streamer aisdk.Streamer = aisdk.NewAnthropicStreamer(client)
+
+	stream, err := streamer.Stream(context.Background(), aisdk.StreamRequest{
+		Model:         "claude-sonnet-4-20250514",
+		Messages:      []aisdk.Message{{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Hi"}}}},
+		Thinking:      2048,
+		StopSequences: []string{"</answer>"},
+		ProviderExtras: map[string]map[string]any{
+			"anthropic": {"metadata": map[string]any{"user_id": "u_1"}},
+		},
+	})
+	require.NoError(t, err)
+
+	var acc aisdk.DataStreamAccumulator
+	require.NoError(t, stream.WithAccumulator(&acc).Drain())
+	require.Equal(t, "Hello!", acc.Messages()[0].Content)
+	require.Equal(t, float64(4096), request.Body["max_tokens"])
+	require.Equal(t, map[string]any{"type": "enabled", "budget_tokens": float64(2048)}, request.Body["thinking"])
+	require.Equal(t, []any{"</answer>"}, request.Body["stop_sequences"])
+	require.Equal(t, map[string]any{"user_id": "u_1"}, request.Body["metadata"])
+
+	_, err = streamer.Stream(context.Background(), aisdk.StreamRequest{Model: "claude-sonnet-4-20250514"})
+	require.EqualError(t, err, "at least one non-system message required")
+}
+
+func TestOpenAIStreamer(t *testing.T) {
+	t.Parallel()
+
+	var request streamerRequest
+	server := newStreamerServer(t, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"o4-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"o4-mini","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"o4-mini","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":8,"total_tokens":18,"completion_tokens_details":{"accepted_prediction_tokens":5,"rejected_prediction_tokens":2}}}
+
+data: [DONE]
+
+`, &request)
+
+	client := openai.NewClient(
+		openaioption.WithBaseURL(server.URL),
+		openaioption.WithAPIKey("sk-test"),
+		openaioption.WithHTTPClient(&http.Client{Transport: headerTransport{}}),
+	)
+	streamer := aisdk.NewOpenAIStreamer(client)
+
+	stream, err := streamer.Stream(context.Background(), aisdk.StreamRequest{
+		Model:         "o4-mini",
+		Messages:      []aisdk.Message{{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Hi"}}}},
+		MaxTokens:     1000,
+		Thinking:      8192,
+		StopSequences: []string{"</answer>"},
+		Prediction:    "Hello!",
+		ProviderExtras: map[string]map[string]any{
+			"openai":    {"seed": 42},
+			"anthropic": {"metadata": map[string]any{"user_id": "u_1"}},
+		},
+	})
+	require.NoError(t, err)
+
+	var acc aisdk.DataStreamAccumulator
+	require.NoError(t, stream.WithAccumulator(&acc).Drain())
+	require.Equal(t, "Hello!", acc.Messages()[0].Content)
+	require.Equal(t, int64(5), *acc.Usage().AcceptedPredictionTokens)
+	require.Equal(t, int64(2), *acc.Usage().RejectedPredictionTokens)
+	require.Equal(t, map[string]any{"type": "content", "content": "Hello!"}, request.Body["prediction"])
+	require.Equal(t, float64(42), request.Body["seed"])
+	require.NotContains(t, request.Body, "metadata")
+	require.Equal(t, float64(1000), request.Body["max_completion_tokens"])
+	require.Equal(t, "medium", request.Body["reasoning_effort"])
+	require.Equal(t, true, request.Body["stream"])
+	require.Equal(t, []any{"</answer>"}, request.Body["stop"])
+
+	_, err = streamer.Stream(context.Background(), aisdk.StreamRequest{
+		Model:         "o4-mini",
+		Messages:      []aisdk.Message{{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Hi"}}}},
+		StopSequences: []string{"a", "b", "c", "d", "e"},
+	})
+	require.EqualError(t, err, "5 stop sequences exceed OpenAI's limit of 4")
+}
+
+func TestAnthropicStreamer_Betas(t *testing.T) {
+	t.Parallel()
+
+	var request streamerRequest
+	server := newStreamerServer(t, anthropicStreamerBody, &request)
+	client := anthropic.NewClient(
+		anthropicoption.WithBaseURL(server.URL),
+		anthropicoption.WithAPIKey("sk-test"),
+		anthropicoption.WithHTTPClient(&http.Client{Transport: headerTransport{}}),
+	)
+	req := aisdk.StreamRequest{
+		Model:     "claude-3-7-sonnet-20250219",
+		Messages:  []aisdk.Message{{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Hi"}}}},
+		MaxTokens: 100_000,
+	}
+
+	_, err := aisdk.NewAnthropicStreamer(client).Stream(context.Background(), req)
+	require.EqualError(t, err, "max tokens of 100000 exceeds Anthropic's limit of 64000")
+
+	streamer := aisdk.NewAnthropicStreamer(client, aisdk.WithAnthropicBetas(
+		anthropic.AnthropicBetaOutput128k2025_02_19,
+		anthropic.AnthropicBeta("context-1m-2025-08-07"),
+	))
+	stream, err := streamer.Stream(context.Background(), req)
+	require.NoError(t, err)
+	require.NoError(t, stream.Drain())
+	require.Equal(t, "output-128k-2025-02-19,context-1m-2025-08-07", request.Header.Get("anthropic-beta"))
+	require.Equal(t, float64(100_000), request.Body["max_tokens"])
+
+	req.MaxTokens = 200_000
+	_, err = streamer.Stream(context.Background(), req)
+	require.EqualError(t, err, "max tokens of 200000 exceeds Anthropic's limit of 128000")
+}
+
+func TestOpenAIStreamer_UnsupportedThinking(t *testing.T) {
+	t.Parallel()
+
+	var request streamerRequest
+	server := newStreamerServer(t, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}]}
+
+data: [DONE]
+
+`, &request)
+	client := openai.NewClient(
+		openaioption.WithBaseURL(server.URL),
+		openaioption.WithAPIKey("sk-test"),
+		openaioption.WithHTTPClient(&http.Client{Transport: headerTransport{}}),
+	)
+	streamer := aisdk.NewOpenAIStreamer(client)
+	req := aisdk.StreamRequest{
+		Model:    "gpt-4o",
+		Messages: []aisdk.Message{{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "Hi"}}}},
+		Thinking: 8192,
+	}
+
+	// The budget is dropped, since gpt-4o doesn't reason.
+	stream, err := streamer.Stream(context.Background(), req)
+	require.NoError(t, err)
+	require.NoError(t, stream.Drain())
+	require.NotContains(t, request.Body, "reasoning_effort")
+
+	req.StrictThinking = true
+	_, err = streamer.Stream(context.Background(), req)
+	require.EqualError(t, err, "model gpt-4o does not support reasoning")
+}
+
+func TestStreamChat(t *testing.T) {
+	t.Parallel()
+
+	var request streamerRequest
+	server := newStreamerServer(t, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"time","arguments":"{}"}}]},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1744123083,"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: [DONE]
+
+`, &request)
+	client := openai.NewClient(
+		openaioption.WithBaseURL(server.URL),
+		openaioption.WithAPIKey("sk-test"),
+		openaioption.WithHTTPClient(&http.Client{Transport: headerTransport{}}),
+	)
+	chat := aisdk.Chat{Messages: []aisdk.Message{{Role: "user", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: "What time is it?"}}}}}
+
+	stream, acc, err := aisdk.StreamChat(context.Background(), chat, aisdk.StreamConfig{
+		Streamer: aisdk.NewOpenAIStreamer(client),
+		Model:    "gpt-4o",
+		Tools:    []aisdk.Tool{{Name: "time", Description: "Returns the current time."}},
+		HandleToolCall: func(toolCall aisdk.ToolCall) any {
+			return "noon"
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, stream.Drain())
+	require.Equal(t, "gpt-4o", request.Body["model"])
+	require.Len(t, request.Body["tools"], 1)
+
+	var results []any
+	for _, part := range acc.Messages()[0].Parts {
+		if part.ToolInvocation != nil {
+			results = append(results, part.ToolInvocation.Result)
+		}
+	}
+	require.Equal(t, []any{"noon"}, results)
+
+	_, _, err = aisdk.StreamChat(context.Background(), chat, aisdk.StreamConfig{Model: "gpt-4o"})
+	require.EqualError(t, err, "stream config has no streamer")
+}
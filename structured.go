@@ -0,0 +1,57 @@
+package aisdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseStructured unmarshals the JSON text of an accumulated message into T,
+// e.g. the response to a request for structured output. Models often wrap
+// JSON in a markdown code fence, sometimes after a sentence of prose, so the
+// content of the first fence is used if there is one.
+//
+//	type Weather struct {
+//		City        string  `json:"city"`
+//		Temperature float64 `json:"temperature"`
+//	}
+//	weather, err := aisdk.ParseStructured[Weather](acc.Messages()[0])
+func ParseStructured[T any](msg Message) (T, error) {
+	var result T
+	text := stripCodeFence(msg.TextContent())
+	if text == "" {
+		return result, errors.New("message has no text to parse as structured output")
+	}
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			if syntaxErr.Offset >= int64(len(text)) {
+				return result, fmt.Errorf("structured output ends before its JSON is complete, e.g. because max tokens was reached: %w", err)
+			}
+			return result, fmt.Errorf("structured output is not valid JSON at offset %d: %w", syntaxErr.Offset, err)
+		}
+		return result, fmt.Errorf("failed to unmarshal structured output into %T: %w", result, err)
+	}
+	return result, nil
+}
+
+// stripCodeFence returns the content of the first markdown code fence in
+// text, or the trimmed text if there is none. A fence that isn't closed, as
+// in a truncated response, runs to the end of the text.
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	start := strings.Index(text, "```")
+	if start < 0 {
+		return text
+	}
+	body := text[start+3:]
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 {
+		// Skip the language of the fence, like json.
+		body = body[newline+1:]
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body)
+}
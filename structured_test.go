@@ -0,0 +1,42 @@
+package aisdk_test
+
+import (
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStructured(t *testing.T) {
+	t.Parallel()
+
+	type weather struct {
+		City        string  `json:"city"`
+		Temperature float64 `json:"temperature"`
+	}
+	message := func(text string) aisdk.Message {
+		return aisdk.Message{Role: "assistant", Parts: []aisdk.Part{{Type: aisdk.PartTypeText, Text: text}}}
+	}
+
+	for _, text := range []string{
+		`{"city":"Paris","temperature":21.5}`,
+		"```json\n{\"city\":\"Paris\",\"temperature\":21.5}\n```",
+		"Here is the weather:\n\n```\n{\"city\":\"Paris\",\"temperature\":21.5}\n```\nAnything else?",
+	} {
+		result, err := aisdk.ParseStructured[weather](message(text))
+		require.NoError(t, err, text)
+		require.Equal(t, weather{City: "Paris", Temperature: 21.5}, result)
+	}
+
+	_, err := aisdk.ParseStructured[weather](message("```json\n{\"city\":\"Par"))
+	require.ErrorContains(t, err, "structured output ends before its JSON is complete")
+
+	_, err = aisdk.ParseStructured[weather](message(`{"city":Paris}`))
+	require.ErrorContains(t, err, "structured output is not valid JSON at offset 9")
+
+	_, err = aisdk.ParseStructured[weather](message(`{"city":42}`))
+	require.ErrorContains(t, err, "failed to unmarshal structured output into aisdk_test.weather")
+
+	_, err = aisdk.ParseStructured[weather](message(" "))
+	require.EqualError(t, err, "message has no text to parse as structured output")
+}
@@ -0,0 +1,14 @@
+package aisdk
+
+import (
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// TogetherToDataStream pipes a Together AI stream to a DataStream.
+// Together sends usage with the finish reason, even without
+// stream_options.include_usage, and some of its models finish with "eos",
+// which is mapped to FinishReasonStop.
+func TogetherToDataStream(stream *ssestream.Stream[openai.ChatCompletionChunk]) DataStream {
+	return openAIToDataStream("together", stream)
+}
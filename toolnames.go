@@ -0,0 +1,114 @@
+package aisdk
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxToolNameLength is the longest tool name OpenAI and Anthropic accept.
+const maxToolNameLength = 64
+
+// SanitizeToolName returns name with every character that providers don't
+// accept in tool names, anything but letters, digits, '_' and '-', replaced
+// by '_', cut to 64 characters. E.g. "github.search" becomes "github_search".
+func SanitizeToolName(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+	if len(sanitized) > maxToolNameLength {
+		sanitized = sanitized[:maxToolNameLength]
+	}
+	return sanitized
+}
+
+// ToolNameMap maps tool names to names the providers accept and back, for
+// tools with names like "github.search". The zero value maps every name to
+// itself.
+//
+//	tools, names := aisdk.SanitizeTools(tools)
+//	messages = names.Messages(messages)
+//	// ... convert tools and messages for the provider and stream ...
+//	stream = stream.WithToolNames(names).WithToolCalling(handleToolCall)
+type ToolNameMap struct {
+	toProvider   map[string]string
+	fromProvider map[string]string
+}
+
+// SanitizeTools returns the tools with names sanitized by SanitizeToolName,
+// and the map between the original and the sanitized names. Names that
+// sanitize to the same name are told apart by a numeric suffix.
+func SanitizeTools(tools []Tool) ([]Tool, ToolNameMap) {
+	names := ToolNameMap{
+		toProvider:   make(map[string]string, len(tools)),
+		fromProvider: make(map[string]string, len(tools)),
+	}
+	sanitized := make([]Tool, len(tools))
+	for i, tool := range tools {
+		base := SanitizeToolName(tool.Name)
+		name := base
+		for n := 2; names.fromProvider[name] != ""; n++ {
+			suffix := fmt.Sprintf("_%d", n)
+			name = base[:min(len(base), maxToolNameLength-len(suffix))] + suffix
+		}
+		names.toProvider[tool.Name] = name
+		names.fromProvider[name] = tool.Name
+		tool.Name = name
+		sanitized[i] = tool
+	}
+	return sanitized, names
+}
+
+// Provider returns the name of the tool sent to the provider.
+func (m ToolNameMap) Provider(name string) string {
+	if providerName, ok := m.toProvider[name]; ok {
+		return providerName
+	}
+	return name
+}
+
+// Original returns the original name of a tool named by the provider.
+func (m ToolNameMap) Original(providerName string) string {
+	if name, ok := m.fromProvider[providerName]; ok {
+		return name
+	}
+	return providerName
+}
+
+// Messages returns a copy of the messages with the tool names of their tool
+// invocations mapped to provider names, so the history matches the tools.
+func (m ToolNameMap) Messages(messages []Message) []Message {
+	mapped := make([]Message, len(messages))
+	for i, message := range messages {
+		message.Parts = append([]Part(nil), message.Parts...)
+		for j, part := range message.Parts {
+			if part.ToolInvocation == nil {
+				continue
+			}
+			invocation := *part.ToolInvocation
+			invocation.ToolName = m.Provider(invocation.ToolName)
+			message.Parts[j].ToolInvocation = &invocation
+		}
+		mapped[i] = message
+	}
+	return mapped
+}
+
+// WithToolNames maps the tool names of tool calls from the provider back to
+// the original names, so that tool handlers and clients see the names the
+// tools were defined with.
+func (s DataStream) WithToolNames(names ToolNameMap) DataStream {
+	return s.Map(func(part DataStreamPart) DataStreamPart {
+		switch p := part.(type) {
+		case ToolCallStartStreamPart:
+			p.ToolName = names.Original(p.ToolName)
+			return p
+		case ToolCallStreamPart:
+			p.ToolName = names.Original(p.ToolName)
+			return p
+		}
+		return part
+	})
+}
@@ -0,0 +1,66 @@
+package aisdk_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/morecommits/aisdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeToolName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "github_search", aisdk.SanitizeToolName("github.search"))
+	require.Equal(t, "fs_read-file_v2", aisdk.SanitizeToolName("fs/read-file v2"))
+	require.Len(t, aisdk.SanitizeToolName(strings.Repeat("a", 100)), 64)
+}
+
+func TestSanitizeTools(t *testing.T) {
+	t.Parallel()
+
+	tools, names := aisdk.SanitizeTools([]aisdk.Tool{
+		{Name: "github.search"},
+		{Name: "github_search"},
+		{Name: "time"},
+	})
+	require.Equal(t, "github_search", tools[0].Name)
+	require.Equal(t, "github_search_2", tools[1].Name)
+	require.Equal(t, "time", tools[2].Name)
+	require.Equal(t, "github.search", names.Original("github_search"))
+	require.Equal(t, "github_search", names.Original("github_search_2"))
+	require.Equal(t, "unknown", names.Original("unknown"))
+
+	messages := []aisdk.Message{{
+		Role: "assistant",
+		Parts: []aisdk.Part{{
+			Type:           aisdk.PartTypeToolInvocation,
+			ToolInvocation: &aisdk.ToolInvocation{ToolCallID: "tool_1", ToolName: "github.search"},
+		}},
+	}}
+	mapped := names.Messages(messages)
+	require.Equal(t, "github_search", mapped[0].Parts[0].ToolInvocation.ToolName)
+	// The input must not be modified.
+	require.Equal(t, "github.search", messages[0].Parts[0].ToolInvocation.ToolName)
+
+	var called string
+	var acc aisdk.DataStreamAccumulator
+	err := partsStream(
+		aisdk.StartStepStreamPart{MessageID: "msg_1"},
+		aisdk.ToolCallStartStreamPart{ToolCallID: "tool_1", ToolName: "github_search"},
+		aisdk.ToolCallStreamPart{ToolCallID: "tool_1", ToolName: "github_search", Args: map[string]any{}},
+		aisdk.FinishMessageStreamPart{FinishReason: aisdk.FinishReasonToolCalls},
+	).WithToolNames(names).WithToolCalling(func(toolCall aisdk.ToolCall) any {
+		called = toolCall.Name
+		return "done"
+	}).WithAccumulator(&acc).Drain()
+	require.NoError(t, err)
+	require.Equal(t, "github.search", called)
+	var toolNames []string
+	for _, part := range acc.Messages()[0].Parts {
+		if part.ToolInvocation != nil {
+			toolNames = append(toolNames, part.ToolInvocation.ToolName)
+		}
+	}
+	require.Equal(t, []string{"github.search"}, toolNames)
+}